@@ -0,0 +1,27 @@
+class CustomError(Exception):
+    pass
+
+
+def raise_value_error():
+    raise ValueError('invalid value', 1)
+
+
+def raise_custom_error():
+    _raise_custom_error()
+
+
+def _raise_custom_error():
+    raise CustomError('custom', {'a': [1, 2]}, object())
+
+
+def raise_io_error():
+    raise IOError('io error')
+
+
+class Outer(object):
+    class NestedError(CustomError):
+        pass
+
+
+def raise_nested_error():
+    raise Outer.NestedError('nested')
@@ -22,3 +22,19 @@ def go2py_arrayinmap(arg):
     ret += '_' + str(arg[0][1])
     ret += '_' + arg[1]['map']
     return ret
+
+
+def go2py_isoformat(arg):
+    return arg.isoformat()
+
+
+def go2py_identity(arg):
+    return arg
+
+
+def go2py_typename(arg):
+    return type(arg).__name__
+
+
+def go2py_add_one(arg):
+    return arg + 1
@@ -1,4 +1,5 @@
 #!/usr/bin/env python
+import sensorbee
 
 
 class PythonTest():
@@ -71,3 +72,72 @@ class PythonTestForKwd(object):
     def confirm_init(self):
         return str(self.a) + '_' + str(self.b) + '_' + str(self.c) + '_' + \
             str(self.d) + '_' + str(self.e)
+
+
+class PythonTestForBatch(object):
+
+    def __init__(self):
+        self.count = 0
+
+    def divide(self, a, b):
+        self.count += 1
+        return a // b
+
+    def get_count(self):
+        return self.count
+
+    def unsupported(self):
+        return object()
+
+    def topology(self, suffix):
+        return sensorbee.topology() + suffix
+
+
+def format_kwd(a, b='b', **c):
+    return '{}_{}_{}'.format(a, b, ','.join(sorted(c.keys())))
+
+
+class PythonTestForCallKw(object):
+
+    def format_kwd(self, a, b='b', **c):
+        return format_kwd(a, b, **c)
+
+
+module_attr = 'module_value'
+
+
+class PythonTestForAttr(object):
+
+    def __init__(self):
+        self.value = 1
+
+    def get_value(self):
+        return self.value
+
+
+class PythonTestForIter(object):
+
+    def __init__(self):
+        self.closed = False
+
+    def count(self, n):
+        try:
+            for i in range(n):
+                yield i
+        finally:
+            self.closed = True
+
+    def fail(self):
+        yield 1
+        raise ValueError('iter error')
+
+    def values(self):
+        return [1, 'a']
+
+    def not_iterable(self):
+        return 1
+
+
+def count(n):
+    for i in range(n):
+        yield {'i': i}
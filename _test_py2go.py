@@ -1,5 +1,11 @@
 #!/usr/bin/env python
+import array
+import collections
 import datetime
+try:
+    from collections.abc import Mapping
+except ImportError:
+    from collections import Mapping
 
 
 def return_true():
@@ -14,6 +20,18 @@ def return_int():
     return 123
 
 
+def return_long():
+    return 2 ** 64 // 2 ** 32
+
+
+def return_min_int():
+    return -2 ** 63
+
+
+def return_big_int():
+    return 2 ** 64
+
+
 def return_float():
     return 1.0
 
@@ -38,6 +56,10 @@ def return_map():
     return {"key1": 123, u"key2": "str"}
 
 
+def return_map_with_non_string_keys():
+    return {1: 'a', 'key': 'b'}
+
+
 def return_nested_map():
     return {"key1": {"key2": 123}}
 
@@ -50,6 +72,10 @@ def return_timestamp():
     return datetime.datetime(2015, 4, 1, 14, 27, 0, 500*1000, None)
 
 
+def return_month_timestamp(month):
+    return datetime.datetime(2015, month, 31 if month == 12 else 1, 23, 59, 59)
+
+
 class TEST_TZ(datetime.tzinfo):
     def utcoffset(self, dt):
         return datetime.timedelta(hours=9, minutes=3)
@@ -65,6 +91,25 @@ def return_timestamp_with_tz():
     return datetime.datetime(2015, 4, 1, 14, 27, 0, 500*1000, TEST_TZ())
 
 
+def return_date():
+    return datetime.date(2015, 4, 1)
+
+
+def return_time():
+    return datetime.time(14, 27, 0, 500*1000)
+
+
+time_value = datetime.time(14, 27, 0, 500*1000)
+
+
+def return_timedelta():
+    return datetime.timedelta(days=1, seconds=3, microseconds=500*1000)
+
+
+def return_negative_timedelta():
+    return -datetime.timedelta(microseconds=1500*1000)
+
+
 def return_onetuple():
     return ('a', {'key1': 1}, [1, 2])
 
@@ -73,6 +118,202 @@ def return_astuple():
     return 'a', {'key1': 1}, [1, 2]
 
 
+def return_set():
+    return set([1])
+
+
+def return_frozenset():
+    return frozenset(['a'])
+
+
+def return_mixed_set():
+    return set([3, 'b', 1.5, None, 'a', (2, 1), (1, 2), True])
+
+
+def return_ordered_dict():
+    return collections.OrderedDict([('key1', 1), ('key2', 2)])
+
+
+def return_default_dict():
+    d = collections.defaultdict(list)
+    d['key'].append(1)
+    return d
+
+
+def return_counter():
+    return collections.Counter(['a', 'b', 'a'])
+
+
+class ListSubclass(list):
+    pass
+
+
+def return_list_subclass():
+    return ListSubclass([1, 2])
+
+
+class CustomMapping(Mapping):
+    def __init__(self, **kwargs):
+        self.d = kwargs
+
+    def __getitem__(self, key):
+        return self.d[key]
+
+    def __iter__(self):
+        return iter(self.d)
+
+    def __len__(self):
+        return len(self.d)
+
+
+def return_custom_mapping():
+    return CustomMapping(key=[1, 2])
+
+
+Point = collections.namedtuple('Point', ['x', 'y'])
+
+
+def return_namedtuple():
+    return Point(1, 2)
+
+
+class FakeNDArray(array.array):
+    # behaves like a one dimensional ndarray supporting the buffer protocol
+    @property
+    def __array_interface__(self):
+        return {'shape': (len(self),), 'typestr': '<f8', 'version': 3}
+
+
+def return_fake_ndarray():
+    return FakeNDArray('d', [1.0, 2.5])
+
+
+def return_fake_int_ndarray():
+    return FakeNDArray('i', [1, -2])
+
+
+class FakeMatrix(object):
+    # behaves like a two dimensional ndarray without the buffer protocol
+    __array_interface__ = {'shape': (2, 2), 'typestr': '<i8', 'version': 3}
+
+    def tolist(self):
+        return [[1, 2], [3, 4]]
+
+
+def return_fake_matrix():
+    return FakeMatrix()
+
+
+class FakeNumPyScalar(object):
+    __array_interface__ = {'shape': (), 'typestr': '<f4', 'version': 3}
+
+    def tolist(self):
+        return 1.5
+
+
+def return_fake_numpy_scalar():
+    return FakeNumPyScalar()
+
+
+def return_numpy_matrix():
+    import numpy
+    return numpy.array([[1.5, 2], [3, 4]], dtype=numpy.float32)[:, ::-1]
+
+
+def return_numpy_scalar():
+    import numpy
+    return numpy.int64(3)
+
+
+def return_uuids():
+    import uuid
+    return [uuid.UUID('12345678-1234-5678-1234-567812345678')]
+
+
+class SensorBeeObject(object):
+    def __sensorbee__(self):
+        return {'kind': 'custom', 'values': [1, 2]}
+
+
+class AsDictObject(object):
+    def _asdict(self):
+        return collections.OrderedDict([('a', 1)])
+
+
+class PlainObject(object):
+    def __init__(self):
+        self.name = 'plain'
+        self.child = AsDictObject()
+
+
+class SlotsObject(object):
+    __slots__ = ('x',)
+
+
+def return_sensorbee_object():
+    return SensorBeeObject()
+
+
+def return_plain_object():
+    return PlainObject()
+
+
+def return_slots_object():
+    return SlotsObject()
+
+
+def return_dataclass():
+    import dataclasses
+    Point = dataclasses.make_dataclass('Point', ['x', 'y'])
+    return [Point(1, 2)]
+
+
+def return_self_referencing_list():
+    a = [1]
+    a.append(a)
+    return a
+
+
+def return_self_referencing_dict():
+    d = {}
+    d['child'] = {'parent': d}
+    return d
+
+
+def return_self_referencing_object():
+    o = PlainObject()
+    o.child = o
+    return o
+
+
+def return_shared_list():
+    a = [1]
+    return [a, a]
+
+
+def return_nested_list(depth):
+    a = []
+    for _ in range(depth - 1):
+        a = [a]
+    return a
+
+
+def return_decimals():
+    import decimal
+    import fractions
+    return [decimal.Decimal('1.50'), fractions.Fraction(3, 4)]
+
+
+def return_non_finite_floats():
+    import decimal
+    return [float('nan'), float('inf'), -float('inf'), decimal.Decimal('NaN'), 1.0]
+
+
+def return_py2_strs():
+    # str keys are always converted into strings
+    return {'utf8': b'\xe3\x81\x82', 'binary': b'\xff', 'unicode': u'\u3042'}
+
+
 def return_object():
     class FailureTest(object):
         def __init__(self):
@@ -0,0 +1,87 @@
+import logging
+import sys
+import warnings
+
+import sensorbee
+
+try:
+    from StringIO import StringIO
+except ImportError:
+    from io import StringIO
+
+
+class State(object):
+
+    def __init__(self):
+        self.value = 'state_value'
+
+
+def log(level, msg, *args):
+    sensorbee.log(level, msg, *args)
+    return True
+
+
+def topology():
+    return sensorbee.topology()
+
+
+def get_state_value(name):
+    return sensorbee.get_state(name).value
+
+
+def emit_all(n):
+    for i in range(n):
+        sensorbee.emit({'i': i})
+    return n
+
+
+def emit_lazily(n):
+    for i in range(n):
+        sensorbee.emit({'i': i})
+        yield i
+
+
+def topology_lazily(n):
+    for i in range(n):
+        yield sensorbee.topology()
+
+
+class _LogOnConversion(object):
+    def __sensorbee__(self):
+        sensorbee.info('converting')
+        return 'logged'
+
+
+def log_on_conversion():
+    return _LogOnConversion()
+
+
+def output_redirected():
+    return isinstance(sys.stdout, sensorbee._LogWriter) and \
+        isinstance(sys.stderr, sensorbee._LogWriter)
+
+
+def write_output(s):
+    sys.stdout.write(s)
+    sys.stderr.write(s)
+    return True
+
+
+def pending_output():
+    return sys.stdout._buf + sys.stderr._buf
+
+
+def write_logs(msg):
+    logging.getLogger('sensorbee_test').error(msg)
+    warnings.warn(msg)
+    return True
+
+
+def write_without_context(s):
+    original = sys.stdout._original
+    sys.stdout._original = StringIO()
+    try:
+        sys.stdout.write(s)
+        return sys.stdout._original.getvalue()
+    finally:
+        sys.stdout._original = original
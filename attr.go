@@ -0,0 +1,133 @@
+package py
+
+/*
+#include "Python.h"
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+
+	"gopkg.in/sensorbee/py.v0/mainthread"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+// GetAttr returns the value of the attribute of the instance.
+func (ins *ObjectInstance) GetAttr(name string) (data.Value, error) {
+	return ins.GetAttrWithOptions(nil, name)
+}
+
+// GetAttrWithOptions returns the value of the attribute like GetAttr. The
+// value is converted with opts. When opts is nil, the default options are
+// used.
+func (ins *ObjectInstance) GetAttrWithOptions(opts *ConvertOptions, name string) (
+	data.Value, error) {
+	return execGetAttr(&ins.Object, name, opts)
+}
+
+// SetAttr sets the value to the attribute of the instance.
+func (ins *ObjectInstance) SetAttr(name string, v data.Value) error {
+	return ins.SetAttrWithOptions(nil, name, v)
+}
+
+// SetAttrWithOptions sets the value to the attribute like SetAttr. The value
+// is converted with opts. When opts is nil, the default options are used.
+func (ins *ObjectInstance) SetAttrWithOptions(opts *ConvertOptions, name string,
+	v data.Value) error {
+	return execSetAttr(&ins.Object, name, v, opts)
+}
+
+// HasAttr returns true when the instance has the attribute.
+func (ins *ObjectInstance) HasAttr(name string) bool {
+	return execHasAttr(&ins.Object, name)
+}
+
+// GetAttr returns the value of the attribute of the module.
+func (m *ObjectModule) GetAttr(name string) (data.Value, error) {
+	return m.GetAttrWithOptions(nil, name)
+}
+
+// GetAttrWithOptions returns the value of the attribute like GetAttr. The
+// value is converted with opts. When opts is nil, the default options are
+// used.
+func (m *ObjectModule) GetAttrWithOptions(opts *ConvertOptions, name string) (
+	data.Value, error) {
+	return execGetAttr(&m.Object, name, opts)
+}
+
+// SetAttr sets the value to the attribute of the module.
+func (m *ObjectModule) SetAttr(name string, v data.Value) error {
+	return m.SetAttrWithOptions(nil, name, v)
+}
+
+// SetAttrWithOptions sets the value to the attribute like SetAttr. The value
+// is converted with opts. When opts is nil, the default options are used.
+func (m *ObjectModule) SetAttrWithOptions(opts *ConvertOptions, name string,
+	v data.Value) error {
+	return execSetAttr(&m.Object, name, v, opts)
+}
+
+// HasAttr returns true when the module has the attribute.
+func (m *ObjectModule) HasAttr(name string) bool {
+	return execHasAttr(&m.Object, name)
+}
+
+func execGetAttr(o *Object, name string, opts *ConvertOptions) (data.Value, error) {
+	type Result struct {
+		val data.Value
+		err error
+	}
+	ch := make(chan *Result)
+	mainthread.Exec(func() {
+		v, err := o.getAttr(name, getConvertOptions(opts))
+		ch <- &Result{v, err}
+	})
+	res := <-ch
+	return res.val, res.err
+}
+
+func execSetAttr(o *Object, name string, v data.Value, opts *ConvertOptions) error {
+	ch := make(chan error)
+	mainthread.Exec(func() {
+		ch <- o.setAttr(name, v, getConvertOptions(opts))
+	})
+	return <-ch
+}
+
+func execHasAttr(o *Object, name string) bool {
+	ch := make(chan bool)
+	mainthread.Exec(func() {
+		ch <- o.p != nil && hasPyAttr(o.p, name)
+	})
+	return <-ch
+}
+
+func (o *Object) getAttr(name string, opts *ConvertOptions) (data.Value, error) {
+	if o.p == nil {
+		return nil, fmt.Errorf("o.p of %p is nil while getting %s", o, name)
+	}
+	a, err := o.getAttrObject(name)
+	if err != nil {
+		return nil, err
+	}
+	defer a.decRef()
+	return fromPyTypeObject(a.p, opts)
+}
+
+func (o *Object) setAttr(name string, v data.Value, opts *ConvertOptions) error {
+	if o.p == nil {
+		return fmt.Errorf("o.p of %p is nil while setting %s", o, name)
+	}
+	pv, err := newPyObj(v, opts)
+	if err != nil {
+		return fmt.Errorf("fail to convert the value of '%v' attribute: %v", name, err)
+	}
+	defer pv.decRef()
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	if C.PyObject_SetAttrString(o.p, cName, pv.p) != 0 {
+		return fmt.Errorf("fail to set '%v' attribute: %w", name, getPyErr())
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package py
+
+/*
+#include "Python.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"gopkg.in/sensorbee/py.v0/mainthread"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+// Py2GoConverter converts a Python object into data.Value. It's called on the
+// main thread while the GIL is held. Therefore, it must not call functions
+// acquiring the GIL, and it should only use methods and functions having a
+// "NoGIL" suffix. o is a borrowed reference and must not be released by the
+// converter.
+type Py2GoConverter func(o Object, opts *ConvertOptions) (data.Value, error)
+
+// Go2PyConverter converts data.Value into a Python object. It returns false
+// when it doesn't handle v so that other converters or the default conversion
+// can convert it. It's called on the main thread while the GIL is held, so the
+// same restriction as Py2GoConverter applies. The returned object is a new
+// reference, which is taken over by the caller.
+type Go2PyConverter func(v data.Value, opts *ConvertOptions) (Object, bool, error)
+
+type namedGo2PyConverter struct {
+	name string
+	conv Go2PyConverter
+}
+
+var (
+	// py2goConverters and go2pyConverters must only be accessed on the main
+	// thread.
+	py2goConverters = map[string]Py2GoConverter{}
+	go2pyConverters []namedGo2PyConverter
+)
+
+// RegisterPy2GoConverter registers a converter for Python objects having the
+// type. The type is identified by its fully qualified name such as
+// "decimal.Decimal" or "uuid.UUID", which is the module name and the
+// qualified name of the type joined with a period. Built-in types don't have
+// a module name such as "range". The converter isn't used for objects of
+// built-in types supported by this package such as int, str, list, dict, or
+// datetime. It takes precedence over other conversions such as ones for
+// subclasses of list or dict, and NumPy objects.
+func RegisterPy2GoConverter(typeName string, c Py2GoConverter) error {
+	if typeName == "" {
+		return errors.New("type name of a converter must not be empty")
+	}
+	if c == nil {
+		return errors.New("converter must not be nil")
+	}
+
+	ch := make(chan error)
+	mainthread.Exec(func() {
+		if _, ok := py2goConverters[typeName]; ok {
+			ch <- fmt.Errorf("converter for '%v' is already registered", typeName)
+			return
+		}
+		py2goConverters[typeName] = c
+		ch <- nil
+	})
+	return <-ch
+}
+
+// UnregisterPy2GoConverter unregisters the converter for the type. It doesn't
+// fail even if no converter is registered for the type.
+func UnregisterPy2GoConverter(typeName string) {
+	mainthread.ExecSync(func() {
+		delete(py2goConverters, typeName)
+	})
+}
+
+// RegisterGo2PyConverter registers a converter which converts data.Value
+// having a specific shape into a Python object. Converters are tried in the
+// order of registration on every value, including values nested in data.Array
+// and data.Map, before the default conversion. The name is only used to
+// identify the converter.
+func RegisterGo2PyConverter(name string, c Go2PyConverter) error {
+	if name == "" {
+		return errors.New("name of a converter must not be empty")
+	}
+	if c == nil {
+		return errors.New("converter must not be nil")
+	}
+
+	ch := make(chan error)
+	mainthread.Exec(func() {
+		for _, r := range go2pyConverters {
+			if r.name == name {
+				ch <- fmt.Errorf("converter '%v' is already registered", name)
+				return
+			}
+		}
+		go2pyConverters = append(go2pyConverters, namedGo2PyConverter{name, c})
+		ch <- nil
+	})
+	return <-ch
+}
+
+// UnregisterGo2PyConverter unregisters the converter having the name. It
+// doesn't fail even if no converter having the name is registered.
+func UnregisterGo2PyConverter(name string) {
+	mainthread.ExecSync(func() {
+		for i, r := range go2pyConverters {
+			if r.name == name {
+				go2pyConverters = append(go2pyConverters[:i:i], go2pyConverters[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
+// fromPy2GoConverter converts o with the converter registered for its type.
+// It returns false when no converter is registered for the type.
+func fromPy2GoConverter(o *C.PyObject, opts *ConvertOptions) (data.Value, bool, error) {
+	if len(py2goConverters) == 0 {
+		return nil, false, nil
+	}
+	name, err := getPyTypeQualifiedName(o)
+	if err != nil {
+		return nil, true, err
+	}
+	c, ok := py2goConverters[name]
+	if !ok {
+		return nil, false, nil
+	}
+	if err := enterPyObject(o, opts); err != nil {
+		return nil, true, err
+	}
+	defer leavePyObject(o)
+	v, err := c(Object{p: o}, opts)
+	if err != nil {
+		return nil, true, fmt.Errorf("fail to convert '%v': %v", name, err)
+	}
+	return v, true, nil
+}
+
+// newPyObjByGo2PyConverters converts v with registered converters. It returns
+// false when none of them converts v.
+func newPyObjByGo2PyConverters(v data.Value, opts *ConvertOptions) (Object, bool, error) {
+	for _, r := range go2pyConverters {
+		o, ok, err := r.conv(v, opts)
+		if err != nil {
+			return Object{}, true, fmt.Errorf("fail to convert a value with '%v': %v",
+				r.name, err)
+		}
+		if ok {
+			return o, true, nil
+		}
+	}
+	return Object{}, false, nil
+}
+
+// getPyTypeQualifiedName returns the fully qualified name of the type of o.
+func getPyTypeQualifiedName(o *C.PyObject) (string, error) {
+	t := Object{p: C.PyObject_Type(o)}
+	defer t.decRef()
+	return getPyClassQualifiedName(t.p)
+}
+
+// getPyClassQualifiedName returns the fully qualified name of the class t.
+// Names of built-in classes don't have a module name.
+func getPyClassQualifiedName(t *C.PyObject) (string, error) {
+	name, err := getPyStringAttr(t, "__qualname__")
+	if err != nil {
+		// Python 2 doesn't have __qualname__.
+		if name, err = getPyStringAttr(t, "__name__"); err != nil {
+			return "", err
+		}
+	}
+	mod, err := getPyStringAttr(t, "__module__")
+	if err != nil {
+		return "", err
+	}
+	switch mod {
+	case "builtins", "__builtin__", "exceptions":
+		return name, nil
+	}
+	return mod + "." + name, nil
+}
+
+// getPyStringAttr returns the attribute of o as a string.
+func getPyStringAttr(o *C.PyObject, name string) (string, error) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	a := C.PyObject_GetAttrString(o, cName)
+	if a == nil {
+		C.PyErr_Clear()
+		return "", fmt.Errorf("cannot get '%v' attribute", name)
+	}
+	defer C.Py_DecRef(a)
+	v, err := fromPyTypeObject(a, withPy2StrString(getConvertOptions(nil)))
+	if err != nil {
+		return "", err
+	}
+	return data.AsString(v)
+}
+
+// errNotMainThread is returned when a function having a "NoGIL" suffix is
+// called outside of the main thread.
+var errNotMainThread = errors.New(
+	"a NoGIL function must be called on the main thread holding the GIL")
+
+// TypeNameNoGIL returns the fully qualified name of the type of the object,
+// which is the key of RegisterPy2GoConverter. This method doesn't acquire the
+// GIL, so it must be called on the main thread holding the GIL, such as in a
+// converter. Otherwise, it returns an error.
+func (o *Object) TypeNameNoGIL() (string, error) {
+	if !mainthread.IsMainThread() {
+		return "", errNotMainThread
+	}
+	return getPyTypeQualifiedName(o.p)
+}
+
+// StrNoGIL returns the result of `str(o)`. This method doesn't acquire the
+// GIL, so it must be called on the main thread holding the GIL, such as in a
+// converter. Otherwise, it returns an error.
+func (o *Object) StrNoGIL() (string, error) {
+	if !mainthread.IsMainThread() {
+		return "", errNotMainThread
+	}
+	v, err := fromPyStr(o.p, getConvertOptions(nil))
+	if err != nil {
+		return "", err
+	}
+	return data.AsString(v)
+}
+
+// GetAttrNoGIL returns the attribute of the object. The returned object must
+// be released with DecRefNoGIL. This method doesn't acquire the GIL, so it
+// must be called on the main thread holding the GIL, such as in a converter.
+// Otherwise, it returns an error.
+func (o *Object) GetAttrNoGIL(name string) (Object, error) {
+	if !mainthread.IsMainThread() {
+		return Object{}, errNotMainThread
+	}
+	return o.getAttrObject(name)
+}
+
+// getAttrObject returns the attribute of the object. User needs to call
+// DecRef.
+func (o *Object) getAttrObject(name string) (Object, error) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	a := C.PyObject_GetAttrString(o.p, cName)
+	if a == nil {
+		return Object{}, fmt.Errorf("fail to get '%v' attribute: %w", name, getPyErr())
+	}
+	return Object{p: a}, nil
+}
+
+// CallNoGIL calls the object with arguments converted by opts. The returned
+// object must be released with DecRefNoGIL. This method doesn't acquire the
+// GIL, so it must be called on the main thread holding the GIL, such as in a
+// converter. Otherwise, it returns an error.
+func (o *Object) CallNoGIL(opts *ConvertOptions, args ...data.Value) (Object, error) {
+	if !mainthread.IsMainThread() {
+		return Object{}, errNotMainThread
+	}
+	f := ObjectFunc{Object: *o, name: "object"}
+	return f.call(args, nil, getConvertOptions(opts), nil)
+}
+
+// ToValueNoGIL converts the object into data.Value with opts. Registered
+// converters are used for nested objects. This method doesn't acquire the
+// GIL, so it must be called on the main thread holding the GIL, such as in a
+// converter. Otherwise, it returns an error.
+func (o *Object) ToValueNoGIL(opts *ConvertOptions) (data.Value, error) {
+	if !mainthread.IsMainThread() {
+		return nil, errNotMainThread
+	}
+	return fromPyTypeObject(o.p, getConvertOptions(opts))
+}
+
+// DecRefNoGIL decreases the reference counter of the object. This method
+// doesn't acquire the GIL, so it must be called on the main thread holding
+// the GIL, such as in a converter. Otherwise, it panics because the object
+// cannot be released safely.
+func (o *Object) DecRefNoGIL() {
+	if o.p == nil {
+		return
+	}
+	if !mainthread.IsMainThread() {
+		panic(errNotMainThread)
+	}
+	C.Py_DecRef(o.p)
+	o.p = nil
+}
+
+// NewObjectNoGIL converts data.Value into a Python object with opts.
+// Registered converters are also used, so a Go2PyConverter calling this
+// function with the value it received causes infinite recursion. The returned
+// object must be released with DecRefNoGIL. This function doesn't acquire the
+// GIL, so it must be called on the main thread holding the GIL, such as in a
+// converter. Otherwise, it returns an error.
+func NewObjectNoGIL(v data.Value, opts *ConvertOptions) (Object, error) {
+	if !mainthread.IsMainThread() {
+		return Object{}, errNotMainThread
+	}
+	return newPyObj(v, getConvertOptions(opts))
+}
+
+// ImportModuleNoGIL imports the module. The returned object must be released
+// with DecRefNoGIL. This function doesn't acquire the GIL, so it must be
+// called on the main thread holding the GIL, such as in a converter.
+// Otherwise, it returns an error.
+func ImportModuleNoGIL(name string) (Object, error) {
+	if !mainthread.IsMainThread() {
+		return Object{}, errNotMainThread
+	}
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	m := C.PyImport_ImportModule(cName)
+	if m == nil {
+		return Object{}, fmt.Errorf("fail to load '%v' module: %w", name, getPyErr())
+	}
+	return Object{p: m}, nil
+}
@@ -0,0 +1,196 @@
+package py
+
+import (
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/py.v0/mainthread"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestPy2GoConverterRegistry(t *testing.T) {
+	Convey("Given an initialized python py2go test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_py2go")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When no converter is registered for UUID", func() {
+			_, err := mdl.Call("return_uuids")
+
+			Convey("Then the conversion should fail", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "unsupported type")
+			})
+		})
+
+		Convey("When registering a converter for UUID", func() {
+			err := RegisterPy2GoConverter("uuid.UUID",
+				func(o Object, opts *ConvertOptions) (data.Value, error) {
+					name, err := o.TypeNameNoGIL()
+					if err != nil {
+						return nil, err
+					}
+					s, err := o.StrNoGIL()
+					if err != nil {
+						return nil, err
+					}
+					return data.Map{"type": data.String(name), "value": data.String(s)}, nil
+				})
+			So(err, ShouldBeNil)
+			Reset(func() {
+				UnregisterPy2GoConverter("uuid.UUID")
+			})
+
+			Convey("Then a nested UUID should be converted by the converter", func() {
+				actual, err := mdl.Call("return_uuids")
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, data.Array{data.Map{
+					"type":  data.String("uuid.UUID"),
+					"value": data.String("12345678-1234-5678-1234-567812345678"),
+				}})
+			})
+
+			Convey("Then registering another converter for UUID should fail", func() {
+				err := RegisterPy2GoConverter("uuid.UUID",
+					func(o Object, opts *ConvertOptions) (data.Value, error) {
+						return data.Null{}, nil
+					})
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When registering a converter returning an error", func() {
+			err := RegisterPy2GoConverter("uuid.UUID",
+				func(o Object, opts *ConvertOptions) (data.Value, error) {
+					return nil, errors.New("converter error")
+				})
+			So(err, ShouldBeNil)
+			Reset(func() {
+				UnregisterPy2GoConverter("uuid.UUID")
+			})
+
+			Convey("Then the conversion should fail with the error", func() {
+				_, err := mdl.Call("return_uuids")
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "converter error")
+			})
+		})
+	})
+}
+
+func TestGo2PyConverterRegistry(t *testing.T) {
+	Convey("Given an initialized python go2py test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_go2py")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When registering a converter creating UUID from a map", func() {
+			err := RegisterGo2PyConverter("uuid", func(v data.Value, opts *ConvertOptions) (Object, bool, error) {
+				m, err := data.AsMap(v)
+				if err != nil || len(m) != 1 {
+					return Object{}, false, nil
+				}
+				s, ok := m["uuid"]
+				if !ok {
+					return Object{}, false, nil
+				}
+
+				uuid, err := ImportModuleNoGIL("uuid")
+				if err != nil {
+					return Object{}, true, err
+				}
+				defer uuid.DecRefNoGIL()
+				c, err := uuid.GetAttrNoGIL("UUID")
+				if err != nil {
+					return Object{}, true, err
+				}
+				defer c.DecRefNoGIL()
+				o, err := c.CallNoGIL(opts, s)
+				return o, true, err
+			})
+			So(err, ShouldBeNil)
+			Reset(func() {
+				UnregisterGo2PyConverter("uuid")
+			})
+
+			Convey("Then the map should be passed as UUID", func() {
+				arg := data.Map{"uuid": data.String("12345678-1234-5678-1234-567812345678")}
+				actual, err := mdl.Call("go2py_typename", arg)
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "UUID")
+
+				Convey("And a nested map should also be passed as UUID", func() {
+					actual, err := mdl.Call("go2py_tostr", data.Array{arg})
+					So(err, ShouldBeNil)
+					So(actual, ShouldContainSubstring, "UUID(")
+				})
+			})
+
+			Convey("Then other maps should be passed as dict", func() {
+				actual, err := mdl.Call("go2py_typename", data.Map{"id": data.Int(1)})
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "dict")
+			})
+
+			Convey("Then an invalid UUID should fail to be converted", func() {
+				_, err := mdl.Call("go2py_typename", data.Map{"uuid": data.String("a")})
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("Then registering another converter with the same name should fail", func() {
+				err := RegisterGo2PyConverter("uuid", func(v data.Value, opts *ConvertOptions) (Object, bool, error) {
+					return Object{}, false, nil
+				})
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestNoGILOutsideMainThread(t *testing.T) {
+	Convey("Given a python object", t, func() {
+		mdl, err := LoadModule("uuid")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When calling NoGIL methods outside of the main thread", func() {
+			Convey("Then they should fail", func() {
+				So(mainthread.IsMainThread(), ShouldBeFalse)
+				_, err := mdl.TypeNameNoGIL()
+				So(err, ShouldNotBeNil)
+				_, err = mdl.GetAttrNoGIL("UUID")
+				So(err, ShouldNotBeNil)
+				_, err = ImportModuleNoGIL("uuid")
+				So(err, ShouldNotBeNil)
+				So(func() { mdl.DecRefNoGIL() }, ShouldPanic)
+			})
+		})
+
+		Convey("When calling NoGIL methods on the main thread", func() {
+			var (
+				name string
+				err  error
+			)
+			mainthread.ExecSync(func() {
+				name, err = mdl.TypeNameNoGIL()
+			})
+
+			Convey("Then they should succeed", func() {
+				So(err, ShouldBeNil)
+				So(name, ShouldEqual, "module")
+			})
+		})
+	})
+}
@@ -16,22 +16,128 @@ int IsPyTypeTimeDelta(PyObject* o) {
   return PyDelta_CheckExact(o);
 }
 
+int IsPyTypeDate(PyObject* o) {
+  return PyDate_CheckExact(o);
+}
+
+int IsPyTypeTime(PyObject* o) {
+  return PyTime_CheckExact(o);
+}
+
 PyObject* GetPyDateTime(int year, int month, int day, int hour, int minute,
-                        int second, int us) {
-  return PyDateTime_FromDateAndTime(year, month, day, hour, minute, second, us);
+                        int second, int us, PyObject* tzinfo) {
+  return PyDateTimeAPI->DateTime_FromDateAndTime(year, month, day, hour,
+      minute, second, us, tzinfo, PyDateTimeAPI->DateTimeType);
 }
 */
 import "C"
 import (
+	"errors"
 	"gopkg.in/sensorbee/py.v0/mainthread"
 	"time"
+	"unsafe"
+)
+
+// fixedOffsetSource defines a function creating a tzinfo having a fixed
+// offset from UTC. Python 2 doesn't have datetime.timezone, so it's defined
+// when it's missing.
+const fixedOffsetSource = `
+import datetime
+
+try:
+    _timezone = datetime.timezone
+except AttributeError:
+    class _timezone(datetime.tzinfo):
+        def __init__(self, offset):
+            self._offset = offset
+
+        def utcoffset(self, dt):
+            return self._offset
+
+        def dst(self, dt):
+            return datetime.timedelta(0)
+
+        def tzname(self, dt):
+            seconds = self._offset.days * 86400 + self._offset.seconds
+            sign = '-' if seconds < 0 else '+'
+            hours, minutes = divmod(abs(seconds) // 60, 60)
+            return 'UTC%s%02d:%02d' % (sign, hours, minutes)
+
+        def __repr__(self):
+            return 'timezone(%r)' % self._offset
+
+
+def fixed_offset(seconds):
+    return _timezone(datetime.timedelta(seconds=seconds))
+`
+
+var (
+	fixedOffsetFunc ObjectFunc
+	pyUTC           Object
 )
 
 func init() {
 	// Lock Native thread for initializing python
-	mainthread.ExecSync(func() {
+	ch := make(chan error)
+	mainthread.Exec(func() {
 		C.init_PyDateTime()
+
+		f, err := loadFixedOffsetFunc()
+		if err != nil {
+			ch <- err
+			return
+		}
+		fixedOffsetFunc = f
+
+		utc := newPyFixedOffset(0)
+		if utc == nil {
+			C.PyErr_Clear()
+			ch <- errors.New("cannot create tzinfo of UTC")
+			return
+		}
+		pyUTC.p = utc
+		ch <- nil
 	})
+	if err := <-ch; err != nil {
+		panic(err)
+	}
+}
+
+// loadFixedOffsetFunc defines fixed_offset function. This function is called
+// while initializing the package, so it doesn't use getPyErr.
+func loadFixedOffsetFunc() (ObjectFunc, error) {
+	errDefine := errors.New("cannot define fixed_offset function")
+	globals := C.PyDict_New()
+	if globals == nil {
+		C.PyErr_Clear()
+		return ObjectFunc{}, errDefine
+	}
+	defer C.Py_DecRef(globals)
+
+	builtins := C.CString("__builtins__")
+	defer C.free(unsafe.Pointer(builtins))
+	if C.PyDict_SetItemString(globals, builtins, C.PyEval_GetBuiltins()) != 0 {
+		C.PyErr_Clear()
+		return ObjectFunc{}, errDefine
+	}
+
+	src := C.CString(fixedOffsetSource)
+	defer C.free(unsafe.Pointer(src))
+	ret := C.PyRun_StringFlags(src, C.Py_file_input, globals, globals, nil)
+	if ret == nil {
+		C.PyErr_Clear()
+		return ObjectFunc{}, errDefine
+	}
+	C.Py_DecRef(ret)
+
+	name := C.CString("fixed_offset")
+	defer C.free(unsafe.Pointer(name))
+	f := C.PyDict_GetItemString(globals, name) // borrowed reference
+	if f == nil {
+		return ObjectFunc{}, errDefine
+	}
+	C.Py_IncRef(f)
+	return ObjectFunc{Object: Object{p: f}, name: "fixed_offset"}, nil
 }
 
 // isPyTypeDateTime checks the object is `PyDateTime` type or not.
@@ -44,8 +150,53 @@ func isPyTypeTimeDelta(o *C.PyObject) bool {
 	return C.IsPyTypeTimeDelta(o) > 0
 }
 
-func getPyDateTime(t time.Time) *C.PyObject {
+// isPyTypeDate checks the object is `PyDate` type or not. It returns false
+// for `PyDateTime` although it's a subtype of `PyDate`.
+func isPyTypeDate(o *C.PyObject) bool {
+	return C.IsPyTypeDate(o) > 0
+}
+
+// isPyTypeTime checks the object is `PyTime` type or not.
+func isPyTypeTime(o *C.PyObject) bool {
+	return C.IsPyTypeTime(o) > 0
+}
+
+// newPyFixedOffset returns a new tzinfo having the given offset from UTC in
+// seconds. It returns nil when Python raised an exception.
+func newPyFixedOffset(offset int) *C.PyObject {
+	arg := C.PyTuple_New(1)
+	if arg == nil {
+		return nil
+	}
+	defer C.Py_DecRef(arg)
+	// PyTuple object takes over the value's reference.
+	C.PyTuple_SetItem(arg, 0, C.PyLong_FromLong(C.long(offset)))
+	return C.PyObject_CallObject(fixedOffsetFunc.p, arg)
+}
+
+// getPyDateTime converts t into a datetime. The tzinfo of the datetime
+// depends on opts.Timezone. It returns nil when Python raised an exception.
+func getPyDateTime(t time.Time, opts *ConvertOptions) *C.PyObject {
+	switch opts.Timezone {
+	case TimezoneUTC:
+		return newPyDateTime(t.UTC(), pyUTC.p)
+
+	case TimezoneOffset:
+		_, offset := t.Zone()
+		tz := newPyFixedOffset(offset)
+		if tz == nil {
+			return nil
+		}
+		defer C.Py_DecRef(tz)
+		return newPyDateTime(t, tz)
+
+	default:
+		return newPyDateTime(t, C.Py_None)
+	}
+}
+
+func newPyDateTime(t time.Time, tzinfo *C.PyObject) *C.PyObject {
 	us := int(t.Nanosecond() / 1e3)
 	return C.GetPyDateTime(C.int(t.Year()), C.int(t.Month()), C.int(t.Day()),
-		C.int(t.Hour()), C.int(t.Minute()), C.int(t.Second()), C.int(us))
+		C.int(t.Hour()), C.int(t.Minute()), C.int(t.Second()), C.int(us), tzinfo)
 }
@@ -0,0 +1,112 @@
+package py
+
+/*
+#include "Python.h"
+*/
+import "C"
+import (
+	"fmt"
+	"regexp"
+	"unsafe"
+
+	"gopkg.in/sensorbee/py.v0/mainthread"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+var (
+	pyDecimalClass  Object
+	pyFractionClass Object
+
+	// decimalStringPattern matches a finite decimal number accepted by
+	// decimal.Decimal.
+	decimalStringPattern = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?$`)
+)
+
+func init() {
+	ch := make(chan error)
+	mainthread.Exec(func() {
+		for _, c := range []struct {
+			module string
+			name   string
+			obj    *Object
+		}{
+			{"decimal", "Decimal", &pyDecimalClass},
+			{"fractions", "Fraction", &pyFractionClass},
+		} {
+			m, err := loadModule(c.module)
+			if err != nil {
+				C.PyErr_Clear()
+				ch <- err
+				return
+			}
+			cName := C.CString(c.name)
+			p := C.PyObject_GetAttrString(m.p, cName)
+			C.free(unsafe.Pointer(cName))
+			m.decRef()
+			if p == nil {
+				C.PyErr_Clear()
+				ch <- fmt.Errorf("cannot load %v.%v", c.module, c.name)
+				return
+			}
+			c.obj.p = p
+		}
+		ch <- nil
+	})
+
+	if err := <-ch; err != nil {
+		panic(err)
+	}
+}
+
+// fromPyDecimal converts a decimal.Decimal or a fractions.Fraction according
+// to opts.Decimal. It returns false when o is neither of them.
+func fromPyDecimal(o *C.PyObject, opts *ConvertOptions) (data.Value, bool, error) {
+	isDecimal, err := isPyInstance(o, pyDecimalClass.p)
+	if err != nil {
+		return nil, true, err
+	}
+	if !isDecimal {
+		if isFraction, err := isPyInstance(o, pyFractionClass.p); err != nil {
+			return nil, true, err
+		} else if !isFraction {
+			return nil, false, nil
+		}
+	}
+
+	switch opts.Decimal {
+	case DecimalString:
+		v, err := fromPyStr(o, opts)
+		return v, true, err
+
+	default:
+		f := C.PyNumber_Float(o)
+		if f == nil {
+			return nil, true, getPyErr()
+		}
+		defer C.Py_DecRef(f)
+		v, err := fromFloat64(float64(C.PyFloat_AsDouble(f)), opts)
+		return v, true, err
+	}
+}
+
+// isDecimalString returns true when s represents a finite decimal number such
+// as "1.50" or "-2e10".
+func isDecimalString(s string) bool {
+	return decimalStringPattern.MatchString(s)
+}
+
+// newPyDecimal creates a decimal.Decimal from its string representation.
+func newPyDecimal(s string) (*C.PyObject, error) {
+	str := newPyString(s)
+	if str == nil {
+		return nil, getPyErr()
+	}
+	defer C.Py_DecRef(str)
+
+	f := ObjectFunc{Object: pyDecimalClass, name: "Decimal"}
+	d, err := f.callObject1(str)
+	if err != nil {
+		return nil, fmt.Errorf("fail to create Decimal from '%v': %v", s, err)
+	}
+	return d.p, nil
+}
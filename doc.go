@@ -15,7 +15,10 @@ Internal: GIL(mainthread.Exec) rules
 1. All private functions or methods MUST NOT acquire the GIL.
 2. All public functions or methods MUST acquire the GIL.
 3. However, public functions or methods having a "NoGIL" suffix MUST NOT
-   acquire the GIL.
+   acquire the GIL. Instead, their callers MUST already hold the GIL on the
+   main thread, that is, they're called in a function passed to
+   mainthread.Exec or in Go code called back from Python such as converters.
+   Exported ones check it with mainthread.IsMainThread.
 
 Therefore, public functions or methods must not call other public ones which
 don't have "NoGIL" suffix.
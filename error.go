@@ -10,6 +10,8 @@ import (
 	"strings"
 	"unicode"
 	"unsafe"
+
+	"gopkg.in/sensorbee/sensorbee.v0/data"
 )
 
 func loadModule(name string) (mod ObjectModule, err error) {
@@ -24,6 +26,7 @@ func loadModule(name string) (mod ObjectModule, err error) {
 }
 
 var tracebackFormatExceptionFunc ObjectFunc
+var tracebackExtractTbFunc ObjectFunc
 var syntaxErrorType Object
 
 // tracebackFormatException calls traceback.format_exception().
@@ -44,7 +47,7 @@ func pyObjectToPyTypeObject(p *C.PyObject) *C.PyTypeObject {
 }
 
 // getPyErr returns a Python's exception as an error.
-// getPyErr normally returns a pyErr (see its godoc for details) and clears
+// getPyErr normally returns an *Error (see its godoc for details) and clears
 // exception state of the python interpreter. If the exception is a MemoryError,
 // getPyErr returns pyNoMemoryError (without stacktrace) and does not clears
 // the exception state. The easiest way to extract stacktrace for MemoryError
@@ -88,30 +91,328 @@ func getPyErr() error {
 		stackTrace += extractLineFromFormattedErrorMessage(formatted, i)
 	}
 	stackTrace = strings.TrimRightFunc(stackTrace, unicode.IsSpace)
-	return &pyErr{
+
+	typ := C.PyTuple_GetItem(excInfo.p, 0)
+	value := C.PyTuple_GetItem(excInfo.p, 1)
+	tb := C.PyTuple_GetItem(excInfo.p, 2)
+	e := &Error{
+		Type:         pyTypeName(typ),
+		Bases:        pyBaseTypeNames(typ),
+		Args:         pyExceptionArgs(value),
+		Traceback:    pyTracebackFrames(tb),
 		mainMsg:      mainMsg,
 		syntaxErrMsg: syntaxErr,
 		stackTrace:   stackTrace,
 	}
+	if isSyntaxError(typ) && value != C.Py_None {
+		e.SyntaxError = &SyntaxErrorLocation{
+			File:   pyErrAttrString(value, "filename"),
+			Line:   pyErrAttrInt(value, "lineno"),
+			Offset: pyErrAttrInt(value, "offset"),
+			Text:   strings.TrimRightFunc(pyErrAttrString(value, "text"), unicode.IsSpace),
+		}
+	}
+	return e
 }
 
-// pyErr represents an exception of python.
-type pyErr struct {
+// Error represents an exception of python. Errors returned from this package
+// can be inspected with errors.As:
+//
+//	var pyErr *py.Error
+//	if errors.As(err, &pyErr) && pyErr.Type == "ValueError" {
+//		...
+//	}
+type Error struct {
+	// Type is the qualified name of the class of the exception such as
+	// "ValueError" or "mymodule.MyError". Names of built-in exceptions don't
+	// have a module name.
+	Type string
+
+	// Bases has qualified names of base classes of the exception in method
+	// resolution order.
+	Bases []string
+
+	// Args has args of the exception. An argument which cannot be converted
+	// with the zero value of ConvertOptions is converted to its string
+	// representation.
+	Args data.Array
+
+	// Traceback has frames of the traceback from the outermost call to the
+	// frame where the exception was raised.
+	Traceback []TracebackFrame
+
+	// SyntaxError has the location of the syntax error when the exception is
+	// a SyntaxError. Otherwise, it's nil.
+	SyntaxError *SyntaxErrorLocation
+
 	mainMsg      string // "main error message" (one line)
 	syntaxErrMsg string // syntax error description for SyntaxError (zero or two lines)
 	stackTrace   string // stacktrace (zero or multiple lines)
 }
 
-// Error returns an error message string for pyErr.
+// TracebackFrame is a frame of a traceback.
+type TracebackFrame struct {
+	File     string
+	Line     int
+	Function string
+}
+
+// SyntaxErrorLocation is the location of a syntax error. Offset is the column
+// where the error was detected starting from 1. Each field is the zero value
+// when Python doesn't provide it.
+type SyntaxErrorLocation struct {
+	File   string
+	Line   int
+	Offset int
+	Text   string
+}
+
+// Error returns an error message string for Error.
 // This string contains multiple lines for stacktrace.
-func (e *pyErr) Error() string {
+func (e *Error) Error() string {
+	if e.syntaxErrMsg == "" && e.stackTrace == "" {
+		return e.mainMsg
+	}
 	return e.mainMsg + "\n" + e.syntaxErrMsg + e.stackTrace
 }
 
+// ClassDepth returns the position of the class named name in the method
+// resolution order of the exception: 0 for the class of the exception, 1 for
+// its direct base class, and so on. It returns -1 when the exception isn't an
+// instance of the class. name is a qualified name like Type. Aliases of
+// built-in exceptions such as IOError of Python 3 are also accepted.
+func (e *Error) ClassDepth(name string) int {
+	if a, ok := exceptionAliases[name]; ok {
+		name = a
+	}
+	if e.Type == name {
+		return 0
+	}
+	for i, b := range e.Bases {
+		if b == name {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// IsInstance returns true when the exception is an instance of the class
+// named name. See ClassDepth for the format of name.
+func (e *Error) IsInstance(name string) bool {
+	return e.ClassDepth(name) >= 0
+}
+
+// pyTypeName returns the qualified name of the exception class.
+func pyTypeName(typ *C.PyObject) string {
+	if typ == C.Py_None {
+		return ""
+	}
+	name, err := getPyClassQualifiedName(typ)
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// pyBaseTypeNames returns qualified names of base classes of the exception
+// class in method resolution order.
+func pyBaseTypeNames(typ *C.PyObject) []string {
+	if typ == C.Py_None {
+		return nil
+	}
+	mro := getPyErrAttr(typ, "__mro__")
+	if mro == nil { // old-style classes of Python 2 don't have __mro__
+		return nil
+	}
+	defer C.Py_DecRef(mro)
+	if C.PySequence_Check(mro) == 0 {
+		return nil
+	}
+	var names []string
+	for i := C.Py_ssize_t(1); i < C.PySequence_Size(mro); i++ {
+		t := C.PySequence_GetItem(mro, i)
+		if t == nil {
+			C.PyErr_Clear()
+			break
+		}
+		names = append(names, pyTypeName(t))
+		C.Py_DecRef(t)
+	}
+	return names
+}
+
+// pyExceptionArgs returns args of the exception.
+func pyExceptionArgs(value *C.PyObject) data.Array {
+	args := data.Array{}
+	if value == C.Py_None {
+		return args
+	}
+	a := getPyErrAttr(value, "args")
+	if a == nil {
+		return args
+	}
+	defer C.Py_DecRef(a)
+	if C.PySequence_Check(a) == 0 {
+		return args
+	}
+	for i := C.Py_ssize_t(0); i < C.PySequence_Size(a); i++ {
+		item := C.PySequence_GetItem(a, i)
+		if item == nil {
+			C.PyErr_Clear()
+			break
+		}
+		args = append(args, pyErrValue(item))
+		C.Py_DecRef(item)
+	}
+	return args
+}
+
+// pyTracebackFrames returns frames of the traceback by traceback.extract_tb.
+func pyTracebackFrames(tb *C.PyObject) []TracebackFrame {
+	if tb == C.Py_None {
+		return nil
+	}
+	args := C.PyTuple_New(1)
+	if args == nil {
+		C.PyErr_Clear()
+		return nil
+	}
+	defer C.Py_DecRef(args)
+	C.Py_IncRef(tb)
+	C.PyTuple_SetItem(args, 0, tb)
+
+	entries := C.PyObject_CallObject(tracebackExtractTbFunc.p, args)
+	if entries == nil {
+		C.PyErr_Clear()
+		return nil
+	}
+	defer C.Py_DecRef(entries)
+
+	n := C.PySequence_Size(entries)
+	frames := make([]TracebackFrame, 0, n)
+	for i := C.Py_ssize_t(0); i < n; i++ {
+		// An entry is (filename, lineno, name, line) or a FrameSummary which
+		// can be unpacked as the tuple.
+		entry := C.PySequence_GetItem(entries, i)
+		if entry == nil {
+			C.PyErr_Clear()
+			break
+		}
+		frames = append(frames, TracebackFrame{
+			File:     pyErrItemString(entry, 0),
+			Line:     pyErrItemInt(entry, 1),
+			Function: pyErrItemString(entry, 2),
+		})
+		C.Py_DecRef(entry)
+	}
+	return frames
+}
+
+// getPyErrAttr returns the attribute or nil without setting an exception.
+// User needs to call DecRef when it isn't nil.
+func getPyErrAttr(o *C.PyObject, name string) *C.PyObject {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	a := C.PyObject_GetAttrString(o, cName)
+	if a == nil {
+		C.PyErr_Clear()
+	}
+	return a
+}
+
+func pyErrAttrString(o *C.PyObject, name string) string {
+	a := getPyErrAttr(o, name)
+	if a == nil {
+		return ""
+	}
+	defer C.Py_DecRef(a)
+	return pyErrString(a)
+}
+
+func pyErrAttrInt(o *C.PyObject, name string) int {
+	a := getPyErrAttr(o, name)
+	if a == nil {
+		return 0
+	}
+	defer C.Py_DecRef(a)
+	return pyErrInt(a)
+}
+
+func pyErrItemString(seq *C.PyObject, i C.Py_ssize_t) string {
+	item := C.PySequence_GetItem(seq, i)
+	if item == nil {
+		C.PyErr_Clear()
+		return ""
+	}
+	defer C.Py_DecRef(item)
+	return pyErrString(item)
+}
+
+func pyErrItemInt(seq *C.PyObject, i C.Py_ssize_t) int {
+	item := C.PySequence_GetItem(seq, i)
+	if item == nil {
+		C.PyErr_Clear()
+		return 0
+	}
+	defer C.Py_DecRef(item)
+	return pyErrInt(item)
+}
+
+// pyErrConvertOptions is used to convert values of an exception. The default
+// options aren't used because they may create handles or call Python code.
+var pyErrConvertOptions = &ConvertOptions{}
+
+// pyErrValue converts o with pyErrConvertOptions. When o cannot be converted,
+// it returns the string representation of o.
+func pyErrValue(o *C.PyObject) data.Value {
+	if v, err := fromPyTypeObject(o, pyErrConvertOptions); err == nil {
+		return v
+	}
+	C.PyErr_Clear()
+	s := C.PyObject_Str(o)
+	if s == nil {
+		C.PyErr_Clear()
+		return data.String("<unprintable object>")
+	}
+	defer C.Py_DecRef(s)
+	v, err := fromPyTypeObject(s, pyErrConvertOptions)
+	if err != nil {
+		C.PyErr_Clear()
+		return data.String("<unprintable object>")
+	}
+	return v
+}
+
+func pyErrString(o *C.PyObject) string {
+	if o == C.Py_None {
+		return ""
+	}
+	s, _ := data.ToString(pyErrValue(o))
+	return s
+}
+
+func pyErrInt(o *C.PyObject) int {
+	v, err := fromPyTypeObject(o, pyErrConvertOptions)
+	if err != nil {
+		C.PyErr_Clear()
+		return 0
+	}
+	i, err := data.AsInt(v)
+	if err != nil {
+		return 0
+	}
+	return int(i)
+}
+
 func isPyNoMemoryError() bool {
 	return C.PyErr_ExceptionMatches(C.PyExc_MemoryError) != 0
 }
 
 // errPyNoMemory is an error value representing an allocation error on Python.
-// This is not a pyErr because it is difficult to extract stacktrace from Python when the heap is exhaused.
-var errPyNoMemory = errors.New("python interpreter failed to allocate memory")
+// It doesn't have args nor stacktrace because it is difficult to extract them from Python when the heap is exhaused.
+var errPyNoMemory = &Error{
+	Type:    "MemoryError",
+	Bases:   memoryErrorBases,
+	Args:    data.Array{},
+	mainMsg: "python interpreter failed to allocate memory",
+}
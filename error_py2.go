@@ -16,6 +16,7 @@ void fetchPythonError(PyObject* excInfo)
 {
   PyObject *type, *value, *traceback;
   PyErr_Fetch(&type, &value, &traceback);
+  PyErr_NormalizeException(&type, &value, &traceback);
   PyTuple_SetItem(excInfo, 0, idOrNone(type));
   PyTuple_SetItem(excInfo, 1, idOrNone(value));
   PyTuple_SetItem(excInfo, 2, idOrNone(traceback));
@@ -29,6 +30,13 @@ import (
 	"gopkg.in/sensorbee/py.v0/mainthread"
 )
 
+// exceptionAliases maps names of built-in exceptions which are aliases of
+// other ones to the actual names. Python 2 doesn't have such exceptions.
+var exceptionAliases = map[string]string{}
+
+// memoryErrorBases are base classes of MemoryError.
+var memoryErrorBases = []string{"StandardError", "Exception", "BaseException", "object"}
+
 func init() {
 	ch := make(chan error)
 	mainthread.Exec(func() {
@@ -44,6 +52,12 @@ func init() {
 			return
 		}
 		tracebackFormatExceptionFunc = formatException
+		extractTb, err := getPyFunc(traceback.p, "extract_tb")
+		if err != nil {
+			ch <- err
+			return
+		}
+		tracebackExtractTbFunc = extractTb
 
 		exceptions, err := loadModule("exceptions")
 		if err != nil {
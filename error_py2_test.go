@@ -4,6 +4,10 @@
 
 package py
 
+// nestedErrorType is the qualified name of _test_error.Outer.NestedError.
+// Python 2 doesn't have __qualname__.
+const nestedErrorType = "_test_error.NestedError"
+
 func loadExceptionModule() (ObjectModule, error) {
 	return LoadModule("exceptions")
 }
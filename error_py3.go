@@ -29,6 +29,16 @@ import (
 	"gopkg.in/sensorbee/py.v0/mainthread"
 )
 
+// exceptionAliases maps names of built-in exceptions which are aliases of
+// other ones to the actual names.
+var exceptionAliases = map[string]string{
+	"IOError":          "OSError",
+	"EnvironmentError": "OSError",
+}
+
+// memoryErrorBases are base classes of MemoryError.
+var memoryErrorBases = []string{"Exception", "BaseException", "object"}
+
 func init() {
 	ch := make(chan error)
 	mainthread.Exec(func() {
@@ -44,6 +54,12 @@ func init() {
 			return
 		}
 		tracebackFormatExceptionFunc = formatException
+		extractTb, err := getPyFunc(traceback.p, "extract_tb")
+		if err != nil {
+			ch <- err
+			return
+		}
+		tracebackExtractTbFunc = extractTb
 
 		syntaxErrorType.p = C.PyExc_SyntaxError
 
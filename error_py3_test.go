@@ -2,6 +2,9 @@
 
 package py
 
+// nestedErrorType is the qualified name of _test_error.Outer.NestedError.
+const nestedErrorType = "_test_error.Outer.NestedError"
+
 func loadExceptionModule() (ObjectModule, error) {
 	return LoadModule("builtins")
 }
@@ -1,9 +1,14 @@
 package py
 
 import (
+	"errors"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/py.v0/mainthread"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
 )
 
 func TestPyError(t *testing.T) {
@@ -34,3 +39,116 @@ func TestPyError(t *testing.T) {
 		})
 	})
 }
+
+func TestPyErrorDetails(t *testing.T) {
+	Convey("Given a python module raising exceptions", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_error")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When a built-in exception is raised", func() {
+			_, err := mdl.Call("raise_value_error")
+			So(err, ShouldNotBeNil)
+
+			Convey("Then it should be inspected as Error", func() {
+				var e *Error
+				So(errors.As(err, &e), ShouldBeTrue)
+				So(e.Type, ShouldEqual, "ValueError")
+				So(e.Args, ShouldResemble, data.Array{data.String("invalid value"), data.Int(1)})
+				So(e.SyntaxError, ShouldBeNil)
+				So(len(e.Traceback), ShouldBeGreaterThan, 0)
+				last := e.Traceback[len(e.Traceback)-1]
+				So(filepath.Base(last.File), ShouldEqual, "_test_error.py")
+				So(last.Line, ShouldEqual, 6)
+				So(last.Function, ShouldEqual, "raise_value_error")
+			})
+		})
+
+		Convey("When a user-defined exception is raised in a nested call", func() {
+			_, err := mdl.Call("raise_custom_error")
+			So(err, ShouldNotBeNil)
+
+			Convey("Then it should have the qualified name and all frames", func() {
+				var e *Error
+				So(errors.As(err, &e), ShouldBeTrue)
+				So(e.Type, ShouldEqual, "_test_error.CustomError")
+				So(len(e.Args), ShouldEqual, 3)
+				So(e.Args[0], ShouldEqual, data.String("custom"))
+				So(e.Args[1], ShouldResemble, data.Map{"a": data.Array{data.Int(1), data.Int(2)}})
+				So(e.Args[2], ShouldHaveSameTypeAs, data.String(""))
+				So(len(e.Traceback), ShouldBeGreaterThanOrEqualTo, 2)
+				n := len(e.Traceback)
+				So(e.Traceback[n-2].Function, ShouldEqual, "raise_custom_error")
+				So(e.Traceback[n-1].Function, ShouldEqual, "_raise_custom_error")
+				So(e.Traceback[n-1].Line, ShouldEqual, 14)
+				So(e.ClassDepth("_test_error.CustomError"), ShouldEqual, 0)
+				So(e.ClassDepth("Exception"), ShouldEqual, 1)
+				So(e.IsInstance("BaseException"), ShouldBeTrue)
+				So(e.IsInstance("ValueError"), ShouldBeFalse)
+			})
+		})
+
+		Convey("When an exception class defined in a class is raised", func() {
+			_, err := mdl.Call("raise_nested_error")
+			So(err, ShouldNotBeNil)
+
+			Convey("Then it should have the qualified name", func() {
+				var e *Error
+				So(errors.As(err, &e), ShouldBeTrue)
+				So(e.Type, ShouldEqual, nestedErrorType)
+				So(e.ClassDepth("_test_error.CustomError"), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When an exception having an unsupported arg is raised with HandleUnsupported", func() {
+			SetDefaultConvertOptions(ConvertOptions{HandleUnsupported: true})
+			Reset(func() {
+				SetDefaultConvertOptions(ConvertOptions{})
+			})
+			_, err := mdl.Call("raise_custom_error")
+			So(err, ShouldNotBeNil)
+
+			Convey("Then the arg should be its string representation instead of a handle", func() {
+				var e *Error
+				So(errors.As(err, &e), ShouldBeTrue)
+				So(len(e.Args), ShouldEqual, 3)
+				So(IsHandle(e.Args[2]), ShouldBeFalse)
+				So(e.Args[2], ShouldHaveSameTypeAs, data.String(""))
+			})
+		})
+
+		Convey("When an IOError is raised", func() {
+			_, err := mdl.Call("raise_io_error")
+			So(err, ShouldNotBeNil)
+
+			Convey("Then it should be an instance of IOError and its bases", func() {
+				var e *Error
+				So(errors.As(err, &e), ShouldBeTrue)
+				So(e.IsInstance("IOError"), ShouldBeTrue)
+				So(e.IsInstance("EnvironmentError"), ShouldBeTrue)
+				So(e.IsInstance("Exception"), ShouldBeTrue)
+				So(e.IsInstance("ValueError"), ShouldBeFalse)
+			})
+		})
+
+		Convey("When a module has a syntax error", func() {
+			_, err := LoadModule("_test_syntax_error")
+			So(err, ShouldNotBeNil)
+
+			Convey("Then it should have the location", func() {
+				var e *Error
+				So(errors.As(err, &e), ShouldBeTrue)
+				So(e.Type, ShouldEqual, "SyntaxError")
+				So(e.SyntaxError, ShouldNotBeNil)
+				So(filepath.Base(e.SyntaxError.File), ShouldEqual, "_test_syntax_error.py")
+				So(e.SyntaxError.Line, ShouldEqual, 10)
+				So(e.SyntaxError.Offset, ShouldBeGreaterThan, 0)
+				So(strings.TrimSpace(e.SyntaxError.Text), ShouldEqual, "print self.hoge = hoge")
+			})
+		})
+	})
+}
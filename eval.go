@@ -0,0 +1,368 @@
+package py
+
+/*
+#include "Python.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"gopkg.in/sensorbee/py.v0/mainthread"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+// compileEvalSource defines compile_eval function which compiles an
+// expression into a lambda. Free variables of the expression become arguments
+// of the lambda in order of their first appearance. Names of builtins aren't
+// arguments. A name is regarded as a module and imported into the namespace
+// only when it's in _MODULES and it's only used as the base of attribute
+// references such as "math" of "math.log". Other names are arguments.
+const compileEvalSource = `
+import ast
+
+_MODULES = frozenset([
+    'math', 'cmath', 're', 'json', 'datetime', 'decimal', 'fractions',
+    'itertools', 'functools', 'operator', 'collections',
+])
+
+def _free_names(tree):
+    loads = []
+    bound = set()
+    attr_bases = set()
+    for node in ast.walk(tree):
+        if isinstance(node, ast.Name):
+            if isinstance(node.ctx, ast.Load):
+                loads.append(node)
+            else:
+                bound.add(node.id)
+        elif hasattr(ast, 'arg') and isinstance(node, ast.arg):
+            bound.add(node.arg)
+        elif isinstance(node, ast.Attribute) and isinstance(node.value, ast.Name):
+            attr_bases.add(id(node.value))
+    loads.sort(key=lambda n: (n.lineno, n.col_offset))
+    names = []
+    non_attr = set()
+    for n in loads:
+        if n.id in bound:
+            continue
+        if n.id not in names:
+            names.append(n.id)
+        if id(n) not in attr_bases:
+            non_attr.add(n.id)
+    modules = set(n for n in names if n in _MODULES and n not in non_attr)
+    return names, modules
+
+def compile_eval(expr, namespace):
+    builtins = namespace['__builtins__']
+    names, modules = _free_names(ast.parse(expr, '<py_eval>', 'eval'))
+    args = []
+    for name in names:
+        if name in builtins:
+            continue
+        if name in modules:
+            namespace[name] = __import__(name)
+            continue
+        args.append(name)
+    f = eval('lambda %s: (%s)' % (', '.join(args), expr), namespace)
+    return (f, args)
+`
+
+var (
+	// evalNamespace is the namespace shared by Exec and Eval.
+	evalNamespace   Object
+	compileEvalFunc ObjectFunc
+)
+
+func init() {
+	ch := make(chan error)
+	mainthread.Exec(func() {
+		ns, err := newPyNamespace("__sensorbee_eval__")
+		if err != nil {
+			ch <- err
+			return
+		}
+		evalNamespace = ns
+
+		helper, err := newPyNamespace("__sensorbee_eval_helper__")
+		if err != nil {
+			ch <- err
+			return
+		}
+		defer helper.decRef()
+		if err := runPySource(compileEvalSource, C.Py_file_input, helper.p, helper.p); err != nil {
+			ch <- fmt.Errorf("cannot define compile_eval function: %v", err)
+			return
+		}
+		name := C.CString("compile_eval")
+		defer C.free(unsafe.Pointer(name))
+		f := C.PyDict_GetItemString(helper.p, name) // borrowed reference
+		if f == nil {
+			ch <- errors.New("cannot define compile_eval function")
+			return
+		}
+		C.Py_IncRef(f)
+		compileEvalFunc = ObjectFunc{Object: Object{p: f}, name: "compile_eval"}
+		ch <- nil
+	})
+	if err := <-ch; err != nil {
+		panic(err)
+	}
+}
+
+// newPyNamespace creates a dict used as globals of a module named name.
+func newPyNamespace(name string) (Object, error) {
+	ns := C.PyDict_New()
+	if ns == nil {
+		return Object{}, getPyErr()
+	}
+
+	for k, v := range map[string]*C.PyObject{
+		"__builtins__": C.PyEval_GetBuiltins(),
+		"__name__":     newPyString(name),
+	} {
+		if v == nil {
+			C.Py_DecRef(ns)
+			return Object{}, getPyErr()
+		}
+		cKey := C.CString(k)
+		res := C.PyDict_SetItemString(ns, cKey, v)
+		C.free(unsafe.Pointer(cKey))
+		if k != "__builtins__" { // builtins is a borrowed reference
+			C.Py_DecRef(v)
+		}
+		if res != 0 {
+			C.Py_DecRef(ns)
+			return Object{}, getPyErr()
+		}
+	}
+	return Object{p: ns}, nil
+}
+
+// runPySource runs source and discards its result.
+func runPySource(source string, start C.int, globals, locals *C.PyObject) error {
+	ret, err := evalPySource(source, start, globals, locals)
+	if err != nil {
+		return err
+	}
+	ret.decRef()
+	return nil
+}
+
+// evalPySource runs source and returns its result. User needs to call DecRef.
+func evalPySource(source string, start C.int, globals, locals *C.PyObject) (Object, error) {
+	src := C.CString(source)
+	defer C.free(unsafe.Pointer(src))
+	ret := C.PyRun_StringFlags(src, start, globals, locals, nil)
+	if ret == nil {
+		return Object{}, getPyErr()
+	}
+	return Object{p: ret}, nil
+}
+
+// setPyDictItems sets all values of m to the dict.
+func setPyDictItems(dict *C.PyObject, m data.Map, opts *ConvertOptions) error {
+	for k, v := range m {
+		o, err := newPyObj(v, opts)
+		if err != nil {
+			return fmt.Errorf("fail to convert '%v': %v", k, err)
+		}
+		cKey := C.CString(k)
+		res := C.PyDict_SetItemString(dict, cKey, o.p)
+		C.free(unsafe.Pointer(cKey))
+		o.decRef()
+		if res != 0 {
+			return getPyErr()
+		}
+	}
+	return nil
+}
+
+// Exec runs Python statements in the namespace shared by Exec and Eval. Values in globals are set to the namespace before running the
+// statements. Names defined by the statements, including imported modules,
+// remain in the namespace and can be used by later calls.
+func Exec(source string, globals data.Map) error {
+	ch := make(chan error)
+	mainthread.Exec(func() {
+		opts := getConvertOptions(nil)
+		if err := setPyDictItems(evalNamespace.p, globals, opts); err != nil {
+			ch <- err
+			return
+		}
+		ch <- runPySource(source, C.Py_file_input, evalNamespace.p, evalNamespace.p)
+	})
+	return <-ch
+}
+
+// Eval evaluates a Python expression in the namespace shared by Exec and
+// Eval. Values in locals can be referred as local variables by the
+// expression. Because they're local, they aren't visible from nested scopes
+// such as lambdas or list comprehensions in Python 3.
+func Eval(expr string, locals data.Map) (data.Value, error) {
+	type Result struct {
+		val data.Value
+		err error
+	}
+	ch := make(chan *Result)
+	mainthread.Exec(func() {
+		v, err := evalExpr(expr, locals, getConvertOptions(nil))
+		ch <- &Result{v, err}
+	})
+	res := <-ch
+	return res.val, res.err
+}
+
+func evalExpr(expr string, locals data.Map, opts *ConvertOptions) (data.Value, error) {
+	l := C.PyDict_New()
+	if l == nil {
+		return nil, getPyErr()
+	}
+	defer C.Py_DecRef(l)
+	if err := setPyDictItems(l, locals, opts); err != nil {
+		return nil, err
+	}
+
+	ret, err := evalPySource(expr, C.Py_eval_input, evalNamespace.p, l)
+	if err != nil {
+		return nil, err
+	}
+	defer ret.decRef()
+	return fromPyTypeObject(ret.p, opts)
+}
+
+// EvalFunc is a precompiled Python expression. Free variables of the
+// expression are its arguments in order of their first appearance. For
+// example, "x ** 2 + math.log(y)" takes two arguments x and y. Each EvalFunc
+// has its own namespace, so names defined by Exec or other expressions aren't
+// visible. Names of builtins aren't arguments. Only some standard modules such
+// as math, re, json, and datetime are regarded as modules, and only when the
+// name is always used as the base of an attribute reference such as "math" of
+// "math.log". They're imported into the namespace when the expression is
+// compiled. Other names are arguments even if modules having the names exist.
+type EvalFunc struct {
+	ObjectFunc
+
+	args []string
+}
+
+// CompileEval compiles a Python expression into EvalFunc.
+func CompileEval(expr string) (*EvalFunc, error) {
+	type Result struct {
+		f   *EvalFunc
+		err error
+	}
+	ch := make(chan *Result)
+	mainthread.Exec(func() {
+		f, err := compileEval(expr)
+		ch <- &Result{f, err}
+	})
+	res := <-ch
+	return res.f, res.err
+}
+
+func compileEval(expr string) (*EvalFunc, error) {
+	pyExpr := newPyString(expr)
+	if pyExpr == nil {
+		return nil, getPyErr()
+	}
+	args := C.PyTuple_New(2)
+	if args == nil {
+		C.Py_DecRef(pyExpr)
+		return nil, getPyErr()
+	}
+	defer C.Py_DecRef(args)
+	// PyTuple object takes over the value's reference.
+	C.PyTuple_SetItem(args, 0, pyExpr)
+	ns, err := newPyNamespace("__sensorbee_eval_func__")
+	if err != nil {
+		return nil, err
+	}
+	C.PyTuple_SetItem(args, 1, ns.p)
+
+	ret, err := compileEvalFunc.callObject(Object{p: args})
+	if err != nil {
+		return nil, fmt.Errorf("fail to compile '%v': %w", expr, err)
+	}
+	defer ret.decRef()
+
+	f := C.PyTuple_GetItem(ret.p, 0) // borrowed reference
+	if f == nil {
+		return nil, getPyErr()
+	}
+	names, err := fromPyTypeObject(C.PyTuple_GetItem(ret.p, 1), getConvertOptions(nil))
+	if err != nil {
+		return nil, err
+	}
+	arr, err := data.AsArray(names)
+	if err != nil {
+		return nil, err
+	}
+	ef := &EvalFunc{
+		ObjectFunc: ObjectFunc{name: "<py_eval>"},
+		args:       make([]string, len(arr)),
+	}
+	for i, n := range arr {
+		if ef.args[i], err = data.AsString(n); err != nil {
+			return nil, err
+		}
+	}
+	C.Py_IncRef(f)
+	ef.p = f
+	return ef, nil
+}
+
+// Args returns names of arguments of the expression.
+func (f *EvalFunc) Args() []string {
+	return f.args
+}
+
+// Call evaluates the expression with args.
+func (f *EvalFunc) Call(args ...data.Value) (data.Value, error) {
+	return f.CallWithOptions(nil, args...)
+}
+
+// CallWithOptions evaluates the expression like Call. Arguments and the
+// result are converted with opts. When opts is nil, the default options are
+// used.
+func (f *EvalFunc) CallWithOptions(opts *ConvertOptions, args ...data.Value) (
+	data.Value, error) {
+	return f.CallWithContext(nil, opts, args...)
+}
+
+// CallWithContext evaluates the expression like CallWithOptions. cc is
+// provided to the "sensorbee" Python module while the expression is being
+// evaluated. See CallContext for details. Because cc is given to each call,
+// an EvalFunc can be shared by callers having different contexts.
+func (f *EvalFunc) CallWithContext(cc *CallContext, opts *ConvertOptions,
+	args ...data.Value) (data.Value, error) {
+	if len(args) != len(f.args) {
+		return nil, fmt.Errorf("the expression takes %v arguments (%v) but %v given",
+			len(f.args), strings.Join(f.args, ", "), len(args))
+	}
+
+	type Result struct {
+		val data.Value
+		err error
+	}
+	ch := make(chan *Result)
+	mainthread.Exec(func() {
+		if f.p == nil {
+			ch <- &Result{nil, errors.New("the expression is already released")}
+			return
+		}
+		opts := getConvertOptions(opts)
+		defer enterCallContext(cc, opts, f.name)()
+		ret, err := f.call(args, nil, opts, cc)
+		if err != nil {
+			ch <- &Result{nil, err}
+			return
+		}
+		defer ret.decRef()
+		v, err := fromPyTypeObject(ret.p, opts)
+		ch <- &Result{v, err}
+	})
+	res := <-ch
+	return res.val, res.err
+}
@@ -0,0 +1,137 @@
+package py
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+func TestExecAndEval(t *testing.T) {
+	Convey("Given a Python namespace", t, func() {
+		Convey("When executing statements with globals", func() {
+			err := Exec("import math\neval_test_y = eval_test_x * 2", data.Map{
+				"eval_test_x": data.Int(3),
+			})
+			So(err, ShouldBeNil)
+
+			Convey("Then defined names should be visible from Eval", func() {
+				v, err := Eval("eval_test_y + a + int(math.floor(1.5))", data.Map{
+					"a": data.Int(1),
+				})
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 8)
+			})
+		})
+
+		Convey("When executing invalid statements", func() {
+			err := Exec("def f(:", nil)
+
+			Convey("Then it should fail", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "SyntaxError")
+			})
+		})
+
+		Convey("When evaluating an expression raising an error", func() {
+			_, err := Eval("1 / 0", nil)
+
+			Convey("Then it should fail", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "ZeroDivisionError")
+			})
+		})
+	})
+}
+
+func TestEvalFunc(t *testing.T) {
+	Convey("Given a compiled expression", t, func() {
+		f, err := CompileEval("x ** 2 + math.log(y) + sum([x for _ in range(y)])")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			f.Release()
+		})
+
+		Convey("Then free variables should be its arguments", func() {
+			So(f.Args(), ShouldResemble, []string{"x", "y"})
+		})
+
+		Convey("When calling it with arguments", func() {
+			v, err := f.Call(data.Int(2), data.Int(1))
+
+			Convey("Then it should return the result", func() {
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 6.0)
+			})
+		})
+
+		Convey("When calling it with wrong number of arguments", func() {
+			_, err := f.Call(data.Int(2))
+
+			Convey("Then it should fail", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "x, y")
+			})
+		})
+	})
+
+	Convey("Given expressions using names of modules", t, func() {
+		cases := []struct {
+			expr string
+			args []string
+			arg  data.Value
+			res  data.Value
+		}{
+			{"time * 2", []string{"time"}, data.Int(3), data.Int(6)},
+			{"os * 2", []string{"os"}, data.Int(3), data.Int(6)},
+			{"array.count(1)", []string{"array"}, data.Array{data.Int(1), data.Int(1)}, data.Int(2)},
+			{"string.upper()", []string{"string"}, data.String("a"), data.String("A")},
+			{"math.real + math", []string{"math"}, data.Float(1.5), data.Float(3)},
+			{"int(math.floor(x))", []string{"x"}, data.Float(1.5), data.Int(1)},
+		}
+
+		for _, c := range cases {
+			c := c
+			Convey(fmt.Sprintf("When compiling %v", c.expr), func() {
+				f, err := CompileEval(c.expr)
+				So(err, ShouldBeNil)
+				Reset(func() {
+					f.Release()
+				})
+
+				Convey("Then only whitelisted modules used as attribute bases should be modules", func() {
+					So(f.Args(), ShouldResemble, c.args)
+					v, err := f.Call(c.arg)
+					So(err, ShouldBeNil)
+					So(v, ShouldResemble, c.res)
+				})
+			})
+		}
+	})
+
+	Convey("Given an expression using a name defined by Exec", t, func() {
+		So(Exec("eval_func_test_z = 1", nil), ShouldBeNil)
+		f, err := CompileEval("eval_func_test_z + 1")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			f.Release()
+		})
+
+		Convey("Then the name should be an argument because the namespace isn't shared", func() {
+			So(f.Args(), ShouldResemble, []string{"eval_func_test_z"})
+			v, err := f.Call(data.Int(2))
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, 3)
+		})
+	})
+
+	Convey("Given an invalid expression", t, func() {
+		_, err := CompileEval("x +")
+
+		Convey("Then it shouldn't be compiled", func() {
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "SyntaxError")
+		})
+	})
+}
@@ -20,8 +20,9 @@ type ObjectFunc struct {
 // invokeDirect calls name's function. User needs to call DecRef.
 // This returns an Object even if there're multiple values returned from python.
 // For example, use to get the object of the class instance that method returned.
+// See ObjectFunc.call for cc.
 func invokeDirect(pyObj *C.PyObject, name string, args []data.Value,
-	kwdArgs data.Map) (resultObject Object, err error) {
+	kwdArgs data.Map, opts *ConvertOptions, cc *CallContext) (resultObject Object, err error) {
 	pyFunc, err := getPyFunc(pyObj, name)
 	if err != nil {
 		return Object{}, fmt.Errorf("fail to get '%v' function: %v", name,
@@ -29,19 +30,32 @@ func invokeDirect(pyObj *C.PyObject, name string, args []data.Value,
 	}
 	defer pyFunc.decRef()
 
-	return pyFunc.call(args, kwdArgs)
+	return pyFunc.call(args, kwdArgs, opts, cc)
 }
 
 // invoke name's function. TODO should be placed at internal package.
-func invoke(pyObj *C.PyObject, name string, args []data.Value, kwdArgs data.Map) (
-	data.Value, error) {
-	ret, err := invokeDirect(pyObj, name, args, kwdArgs)
+func invoke(pyObj *C.PyObject, name string, args []data.Value, kwdArgs data.Map,
+	opts *ConvertOptions, cc *CallContext) (data.Value, error) {
+	pyFunc, err := getPyFunc(pyObj, name)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get '%v' function: %v", name,
+			err.Error())
+	}
+	defer pyFunc.decRef()
+
+	return pyFunc.invoke(args, kwdArgs, opts, cc)
+}
+
+// invokeNested calls name's method of a value being converted and converts
+// its return value. Unlike invoke, the return value is always converted
+// because opts.KeepResult doesn't affect nested values.
+func invokeNested(pyObj *C.PyObject, name string, opts *ConvertOptions) (data.Value, error) {
+	ret, err := invokeDirect(pyObj, name, nil, nil, opts, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer ret.decRef()
-
-	return fromPyTypeObject(ret.p)
+	return fromPyTypeObject(ret.p, opts)
 }
 
 func getPyFunc(pyObj *C.PyObject, name string) (ObjectFunc, error) {
@@ -65,15 +79,42 @@ func getPyFunc(pyObj *C.PyObject, name string) (ObjectFunc, error) {
 
 // TODO: provide Call which acquires GIL
 
-func (f *ObjectFunc) call(args []data.Value, kwdArgs data.Map) (result Object, resErr error) {
+// invoke calls the function and converts the return value with opts. The
+// return value is kept as a handle when opts.KeepResult is true. See call for
+// cc.
+func (f *ObjectFunc) invoke(args []data.Value, kwdArgs data.Map, opts *ConvertOptions,
+	cc *CallContext) (data.Value, error) {
+	// The context is kept while the return value is converted because it
+	// may call Python code such as __sensorbee__ method.
+	defer enterCallContext(cc, opts, f.name)()
+	ret, err := f.call(args, kwdArgs, opts, cc)
+	if err != nil {
+		return nil, err
+	}
+	defer ret.decRef()
+
+	if opts.KeepResult {
+		return newPyHandle(ret.p, opts), nil
+	}
+	return fromPyTypeObject(ret.p, opts)
+}
+
+// call calls the function. cc is provided to the sensorbee Python module while
+// the function is running. When cc is nil, the function uses the CallContext
+// of the function being called, if any, so that Python code called during
+// conversions keeps the context.
+func (f *ObjectFunc) call(args []data.Value, kwdArgs data.Map, opts *ConvertOptions,
+	cc *CallContext) (result Object, resErr error) {
 	defer func() {
 		if r := recover(); r != nil {
 			resErr = fmt.Errorf("cannot call '%v' due to panic: %v", f.name, r)
 		}
 	}()
 
+	defer enterCallContext(cc, opts, f.name)()
+
 	// no named arguments
-	pyArg, err := convertArgsGo2Py(args)
+	pyArg, err := convertArgsGo2Py(args, opts)
 	if err != nil {
 		return Object{}, fmt.Errorf(
 			"fail to convert argument in calling '%v' function: %v", f.name,
@@ -86,7 +127,7 @@ func (f *ObjectFunc) call(args []data.Value, kwdArgs data.Map) (result Object, r
 	if len(kwdArgs) == 0 {
 		ret, err = f.callObject(pyArg)
 	} else {
-		pyKwdArg, localErr := newPyObj(kwdArgs)
+		pyKwdArg, localErr := newPyObj(kwdArgs, opts)
 		if localErr != nil {
 			return Object{}, fmt.Errorf(
 				"fail to convert named arguments in calling '%v' function: %v",
@@ -97,8 +138,7 @@ func (f *ObjectFunc) call(args []data.Value, kwdArgs data.Map) (result Object, r
 	}
 
 	if err != nil {
-		return Object{}, fmt.Errorf("fail to call '%v' function: %v", f.name,
-			err.Error())
+		return Object{}, fmt.Errorf("fail to call '%v' function: %w", f.name, err)
 	}
 	return ret, nil
 }
@@ -124,7 +164,7 @@ func (f *ObjectFunc) callObjectWithKwd(kwdArg Object, arg Object) (Object, error
 	return Object{p: po}, nil
 }
 
-func convertArgsGo2Py(args []data.Value) (Object, error) {
+func convertArgsGo2Py(args []data.Value, opts *ConvertOptions) (Object, error) {
 	pyArg := C.PyTuple_New(C.Py_ssize_t(len(args)))
 	if pyArg == nil {
 		return Object{}, getPyErr()
@@ -136,7 +176,7 @@ func convertArgsGo2Py(args []data.Value) (Object, error) {
 		}
 	}()
 	for i, v := range args {
-		o, err := newPyObj(v)
+		o, err := newPyObj(v, opts)
 		if err != nil {
 			return Object{}, err
 		}
@@ -73,7 +73,7 @@ func TestPyFunc(t *testing.T) {
 
 		Convey("When calling a function object properly", func() {
 			arg, err := safePythonCall(func() (Object, error) {
-				return convertArgsGo2Py([]data.Value{data.Int(1)})
+				return convertArgsGo2Py([]data.Value{data.Int(1)}, &defaultConvertOptions)
 			})
 			So(err, ShouldBeNil)
 			Reset(func() {
@@ -93,7 +93,7 @@ func TestPyFunc(t *testing.T) {
 
 		Convey("When calling a function object with incorect number of arguments", func() {
 			badArg, err := safePythonCall(func() (Object, error) {
-				return convertArgsGo2Py(nil)
+				return convertArgsGo2Py(nil, &defaultConvertOptions)
 			})
 			So(err, ShouldBeNil)
 			Reset(func() {
@@ -28,14 +28,25 @@ func newPyString(s string) *C.PyObject {
 	return C.PyUnicode_FromString(cs)
 }
 
-func newPyArray(a data.Array) (*C.PyObject, error) {
+func newPyArray(a data.Array, opts *ConvertOptions) (*C.PyObject, error) {
+	if err := enterValue(a, opts); err != nil {
+		return nil, err
+	}
+	defer leaveValue()
+
+	if opts.FloatArrayAsNDArray && isFloatArray(a) {
+		return newPyNDArray(a)
+	}
+
 	pylist := C.PyList_New(C.Py_ssize_t(len(a)))
 	if pylist == nil {
 		return nil, getPyErr()
 	}
 	for i, v := range a {
-		value, err := newPyObj(v)
+		value, err := newPyObj(v, opts)
 		if err != nil {
+			// Items which aren't set yet are NULL and ignored by the list.
+			C.Py_DecRef(pylist)
 			return nil, err
 		}
 		// PyList object takes over the value's reference, and not need to
@@ -45,7 +56,12 @@ func newPyArray(a data.Array) (*C.PyObject, error) {
 	return pylist, nil
 }
 
-func newPyMap(m data.Map) (*C.PyObject, error) {
+func newPyMap(m data.Map, opts *ConvertOptions) (*C.PyObject, error) {
+	if err := enterValue(m, opts); err != nil {
+		return nil, err
+	}
+	defer leaveValue()
+
 	pydict := C.PyDict_New()
 	if pydict == nil {
 		return nil, getPyErr()
@@ -54,7 +70,7 @@ func newPyMap(m data.Map) (*C.PyObject, error) {
 		err := func() error {
 			ck := C.CString(k)
 			defer C.free(unsafe.Pointer(ck))
-			value, err := newPyObj(v)
+			value, err := newPyObj(v, opts)
 			if err != nil {
 				return err
 			}
@@ -63,6 +79,7 @@ func newPyMap(m data.Map) (*C.PyObject, error) {
 			return nil
 		}()
 		if err != nil {
+			C.Py_DecRef(pydict)
 			return nil, err
 		}
 	}
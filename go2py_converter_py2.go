@@ -15,7 +15,15 @@ import (
 	"gopkg.in/sensorbee/sensorbee.v0/data"
 )
 
-func newPyObj(v data.Value) (Object, error) {
+func newPyObj(v data.Value, opts *ConvertOptions) (Object, error) {
+	if p, ok, err := resolvePyHandle(v); ok {
+		return Object{p: p}, err
+	}
+
+	if o, ok, err := newPyObjByGo2PyConverters(v, opts); ok {
+		return o, err
+	}
+
 	var pyobj *C.PyObject
 	var err error
 	switch v.Type() {
@@ -30,7 +38,11 @@ func newPyObj(v data.Value) (Object, error) {
 		pyobj = C.PyFloat_FromDouble(C.double(f))
 	case data.TypeString:
 		s, _ := data.AsString(v)
-		pyobj = newPyString(s)
+		if opts.StringAsDecimal && isDecimalString(s) {
+			pyobj, err = newPyDecimal(s)
+		} else {
+			pyobj = newPyString(s)
+		}
 	case data.TypeBlob:
 		b, _ := data.AsBlob(v)
 		if len(b) == 0 {
@@ -41,13 +53,13 @@ func newPyObj(v data.Value) (Object, error) {
 		}
 	case data.TypeTimestamp:
 		t, _ := data.AsTimestamp(v)
-		pyobj = getPyDateTime(t)
+		pyobj = getPyDateTime(t, opts)
 	case data.TypeArray:
 		innerArray, _ := data.AsArray(v)
-		pyobj, err = newPyArray(innerArray)
+		pyobj, err = newPyArray(innerArray, opts)
 	case data.TypeMap:
 		innerMap, _ := data.AsMap(v)
-		pyobj, err = newPyMap(innerMap)
+		pyobj, err = newPyMap(innerMap, opts)
 	case data.TypeNull:
 		pyobj = getPyNone()
 	default:
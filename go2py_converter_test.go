@@ -55,6 +55,53 @@ func TestConvertGo2PyObject(t *testing.T) {
 			})
 		})
 
+		Convey("When set a time value having a time zone", func() {
+			jst := time.FixedZone("JST", 9*60*60)
+			ts := data.Timestamp(time.Date(2015, time.May, 1, 14, 24, 0, 0, jst))
+
+			Convey("Then function should receive a naive datetime by default", func() {
+				actual, err := mdl.Call("go2py_isoformat", ts)
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "2015-05-01T14:24:00")
+			})
+
+			Convey("Then function should receive a datetime in UTC with TimezoneUTC", func() {
+				SetDefaultConvertOptions(ConvertOptions{Timezone: TimezoneUTC})
+				Reset(func() {
+					SetDefaultConvertOptions(ConvertOptions{})
+				})
+				actual, err := mdl.Call("go2py_isoformat", ts)
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "2015-05-01T05:24:00+00:00")
+
+				Convey("And the instant should be kept in a round trip", func() {
+					actual, err := mdl.Call("go2py_identity", ts)
+					So(err, ShouldBeNil)
+					t, err := data.AsTimestamp(actual)
+					So(err, ShouldBeNil)
+					So(t.Equal(time.Time(ts)), ShouldBeTrue)
+				})
+			})
+
+			Convey("Then function should receive a datetime with the offset with TimezoneOffset", func() {
+				SetDefaultConvertOptions(ConvertOptions{Timezone: TimezoneOffset})
+				Reset(func() {
+					SetDefaultConvertOptions(ConvertOptions{})
+				})
+				actual, err := mdl.Call("go2py_isoformat", ts)
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "2015-05-01T14:24:00+09:00")
+
+				Convey("And the instant should be kept in a round trip", func() {
+					actual, err := mdl.Call("go2py_identity", ts)
+					So(err, ShouldBeNil)
+					t, err := data.AsTimestamp(actual)
+					So(err, ShouldBeNil)
+					So(t.Equal(time.Time(ts)), ShouldBeTrue)
+				})
+			})
+		})
+
 		Convey("When set a byte array", func() {
 			b := data.Blob([]byte("ABC"))
 			Convey("Then function should return string", func() {
@@ -111,3 +158,134 @@ func TestConvertGo2PyObject(t *testing.T) {
 		})
 	})
 }
+
+func TestConvertDeepGo2PyObject(t *testing.T) {
+	Convey("Given an initialized python go2py test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_go2py")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When converting nested values with MaxDepth", func() {
+			SetDefaultConvertOptions(ConvertOptions{MaxDepth: 3})
+			Reset(func() {
+				SetDefaultConvertOptions(ConvertOptions{})
+			})
+
+			Convey("Then values nested up to the depth should be converted", func() {
+				arg := data.Array{data.Map{"a": data.Array{}}}
+				actual, err := mdl.Call("go2py_identity", arg)
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, arg)
+			})
+
+			Convey("Then deeper values should fail to be converted", func() {
+				arg := data.Array{data.Map{"a": data.Array{data.Array{}}}}
+				_, err := mdl.Call("go2py_identity", arg)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "maximum depth")
+			})
+		})
+
+		Convey("When converting a map having a circular reference", func() {
+			m := data.Map{}
+			m["self"] = m
+			_, err := mdl.Call("go2py_identity", m)
+
+			Convey("Then the conversion should fail", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "maximum depth")
+			})
+		})
+	})
+}
+
+func TestConvertGo2PyDecimal(t *testing.T) {
+	Convey("Given an initialized python go2py test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_go2py")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When converting strings with StringAsDecimal", func() {
+			SetDefaultConvertOptions(ConvertOptions{
+				Decimal:         DecimalString,
+				StringAsDecimal: true,
+			})
+			Reset(func() {
+				SetDefaultConvertOptions(ConvertOptions{})
+			})
+
+			Convey("Then a decimal number should be passed as Decimal", func() {
+				actual, err := mdl.Call("go2py_typename", data.String("-1.25e3"))
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "Decimal")
+
+				Convey("And it should be calculated without losing precision", func() {
+					actual, err := mdl.Call("go2py_add_one", data.String("0.10"))
+					So(err, ShouldBeNil)
+					So(actual, ShouldEqual, "1.10")
+				})
+			})
+
+			Convey("Then other strings should be passed as strings", func() {
+				for _, s := range []string{"abc", "NaN", "1.2.3", ""} {
+					actual, err := mdl.Call("go2py_typename", data.String(s))
+					So(err, ShouldBeNil)
+					So(actual, ShouldNotEqual, "Decimal")
+				}
+			})
+		})
+	})
+}
+
+func TestConvertGo2NumPyObject(t *testing.T) {
+	if _, err := LoadModule("numpy"); err != nil {
+		t.Skip("numpy is not installed")
+	}
+
+	Convey("Given an initialized python go2py test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_go2py")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When converting float arrays with FloatArrayAsNDArray", func() {
+			SetDefaultConvertOptions(ConvertOptions{FloatArrayAsNDArray: true})
+			Reset(func() {
+				SetDefaultConvertOptions(ConvertOptions{})
+			})
+
+			Convey("Then an array of floats should be passed as an ndarray", func() {
+				arg := data.Array{data.Float(1.5), data.Float(2.5)}
+				actual, err := mdl.Call("go2py_typename", arg)
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "ndarray")
+
+				Convey("And it should be converted back into the same array", func() {
+					actual, err := mdl.Call("go2py_identity", arg)
+					So(err, ShouldBeNil)
+					So(actual, ShouldResemble, arg)
+				})
+			})
+
+			Convey("Then an array having other values should be passed as a list", func() {
+				actual, err := mdl.Call("go2py_typename", data.Array{data.Float(1.5), data.Int(2)})
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "list")
+			})
+		})
+	})
+}
@@ -0,0 +1,248 @@
+package py
+
+/*
+#include "Python.h"
+*/
+import "C"
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/sensorbee/py.v0/mainthread"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+// HandleKey is the only key of data.Map representing a handle of a Python
+// object. Such a map is passed to Python as the original object.
+const HandleKey = "__sensorbee_py_handle__"
+
+// DefaultHandleTTL is the TTL of a handle when ConvertOptions.HandleTTL is 0.
+const DefaultHandleTTL = 10 * time.Minute
+
+// handleSweepInterval is the minimum interval of releasing expired handles.
+const handleSweepInterval = time.Second
+
+type pyHandle struct {
+	p       *C.PyObject
+	ttl     time.Duration
+	expires time.Time
+	owner   interface{}
+}
+
+var (
+	// handles has Python objects kept as handles. Their IDs are random so
+	// that a handle cannot be forged from another handle. IDs are never
+	// reused while the handle is alive. These variables must only be
+	// accessed on the main thread.
+	handles = map[int64]*pyHandle{}
+
+	// handleSweepTimer releases expired handles at handleSweepAt. It's nil
+	// when no sweep is scheduled.
+	handleSweepTimer *time.Timer
+	handleSweepAt    time.Time
+)
+
+func (opts *ConvertOptions) handleTTL() time.Duration {
+	if opts.HandleTTL <= 0 {
+		return DefaultHandleTTL
+	}
+	return opts.HandleTTL
+}
+
+// newPyHandle keeps o in the handle table and returns data.Map representing
+// its handle. The handle has a new reference of o.
+func newPyHandle(o *C.PyObject, opts *ConvertOptions) data.Value {
+	id := newHandleID()
+	C.Py_IncRef(o)
+	ttl := opts.handleTTL()
+	h := &pyHandle{
+		p:       o,
+		ttl:     ttl,
+		expires: time.Now().Add(ttl),
+		owner:   opts.HandleOwner,
+	}
+	handles[id] = h
+	scheduleHandleSweep(h.expires)
+	return data.Map{HandleKey: data.Int(id)}
+}
+
+// newHandleID returns a random positive ID which isn't used by other handles.
+func newHandleID() int64 {
+	var b [8]byte
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			panic(fmt.Errorf("cannot generate the id of a handle: %v", err))
+		}
+		id := int64(binary.LittleEndian.Uint64(b[:]) >> 1)
+		if _, ok := handles[id]; id != 0 && !ok {
+			return id
+		}
+	}
+}
+
+// getHandleID returns the ID of the handle when v represents a handle.
+func getHandleID(v data.Value) (int64, bool) {
+	m, err := data.AsMap(v)
+	if err != nil || len(m) != 1 {
+		return 0, false
+	}
+	id, ok := m[HandleKey]
+	if !ok || id.Type() != data.TypeInt {
+		return 0, false
+	}
+	i, _ := data.AsInt(id)
+	return i, true
+}
+
+// resolvePyHandle returns a new reference of the Python object kept as the
+// handle represented by v. Resolving a handle extends its TTL. It returns
+// false when v isn't a handle.
+func resolvePyHandle(v data.Value) (*C.PyObject, bool, error) {
+	id, ok := getHandleID(v)
+	if !ok {
+		return nil, false, nil
+	}
+
+	now := time.Now()
+	h, ok := handles[id]
+	if ok && now.After(h.expires) {
+		releasePyHandle(id)
+		ok = false
+	}
+	if !ok {
+		return nil, true, fmt.Errorf("python object handle %v is released or expired", id)
+	}
+	h.expires = now.Add(h.ttl)
+	C.Py_IncRef(h.p)
+	return h.p, true, nil
+}
+
+func releasePyHandle(id int64) bool {
+	h, ok := handles[id]
+	if !ok {
+		return false
+	}
+	delete(handles, id)
+	C.Py_DecRef(h.p)
+	return true
+}
+
+// scheduleHandleSweep makes sure that expired handles are released at t or
+// earlier. Handles are released even if no handle is created or resolved
+// afterwards.
+func scheduleHandleSweep(t time.Time) {
+	if handleSweepTimer != nil {
+		if !t.Before(handleSweepAt) {
+			return
+		}
+		handleSweepTimer.Stop()
+	}
+	if min := time.Now().Add(handleSweepInterval); t.Before(min) {
+		t = min
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(t.Sub(time.Now()), func() {
+		mainthread.Exec(func() {
+			if handleSweepTimer != timer {
+				return // The timer has been stopped after it fired.
+			}
+			handleSweepTimer = nil
+			sweepPyHandles(time.Now())
+		})
+	})
+	handleSweepTimer = timer
+	handleSweepAt = t
+}
+
+// sweepPyHandles releases expired handles and schedules the next sweep when
+// there're remaining handles.
+func sweepPyHandles(now time.Time) {
+	var next time.Time
+	for id, h := range handles {
+		if now.After(h.expires) {
+			releasePyHandle(id)
+		} else if next.IsZero() || h.expires.Before(next) {
+			next = h.expires
+		}
+	}
+	if !next.IsZero() {
+		scheduleHandleSweep(next)
+	}
+}
+
+// releasePyHandlesOf releases all handles created with owner.
+func releasePyHandlesOf(owner interface{}) int {
+	n := 0
+	for id, h := range handles {
+		if h.owner == owner {
+			releasePyHandle(id)
+			n++
+		}
+	}
+	return n
+}
+
+// IsHandle returns true when v represents a handle of a Python object.
+func IsHandle(v data.Value) bool {
+	_, ok := getHandleID(v)
+	return ok
+}
+
+// NewHandle keeps the Python object and returns a handle of it. The handle is
+// resolved to the object when it's passed to Python, so a Python object held
+// by Go can be passed as an argument. The TTL of the handle is decided by opts.
+// When opts is nil, the default options are used. The handle should be
+// released by ReleaseHandle after it's used.
+func NewHandle(o *Object, opts *ConvertOptions) (data.Value, error) {
+	type Result struct {
+		val data.Value
+		err error
+	}
+	ch := make(chan *Result)
+	mainthread.Exec(func() {
+		if o.p == nil {
+			ch <- &Result{nil, fmt.Errorf("o.p of %p is nil while creating a handle", o)}
+			return
+		}
+		ch <- &Result{newPyHandle(o.p, getConvertOptions(opts)), nil}
+	})
+	res := <-ch
+	return res.val, res.err
+}
+
+// ReleaseHandle releases the Python object kept as the handle represented by
+// v. The handle cannot be used after it's released. It returns an error when
+// v isn't a handle or the handle has already been released or expired.
+func ReleaseHandle(v data.Value) error {
+	id, ok := getHandleID(v)
+	if !ok {
+		return errors.New("the value isn't a handle of a python object")
+	}
+	ch := make(chan bool)
+	mainthread.Exec(func() {
+		ch <- releasePyHandle(id)
+	})
+	if !<-ch {
+		return fmt.Errorf("python object handle %v is already released or expired", id)
+	}
+	return nil
+}
+
+// ReleaseHandles releases all handles created with ConvertOptions whose
+// HandleOwner is owner. It returns the number of released handles. It's
+// called when the owner, such as a state, is terminated so that its handles
+// don't keep Python objects until they expire. owner must not be nil.
+func ReleaseHandles(owner interface{}) int {
+	if owner == nil {
+		return 0
+	}
+	ch := make(chan int)
+	mainthread.Exec(func() {
+		ch <- releasePyHandlesOf(owner)
+	})
+	return <-ch
+}
@@ -0,0 +1,160 @@
+package py
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/py.v0/mainthread"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+	"time"
+)
+
+func TestPyObjectHandle(t *testing.T) {
+	Convey("Given initialized python py2go and go2py test modules", t, func() {
+		mainthread.AppendSysPath("")
+
+		py2go, err := LoadModule("_test_py2go")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			py2go.Release()
+		})
+		go2py, err := LoadModule("_test_go2py")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			go2py.Release()
+		})
+
+		Convey("When converting an unsupported object with HandleUnsupported", func() {
+			SetDefaultConvertOptions(ConvertOptions{HandleUnsupported: true})
+			Reset(func() {
+				SetDefaultConvertOptions(ConvertOptions{})
+			})
+			h, err := py2go.Call("return_object")
+			So(err, ShouldBeNil)
+
+			Convey("Then it should be converted into a handle", func() {
+				So(IsHandle(h), ShouldBeTrue)
+
+				Convey("And the handle should be passed as the original object", func() {
+					actual, err := go2py.Call("go2py_typename", data.Array{h})
+					So(err, ShouldBeNil)
+					So(actual, ShouldEqual, "list")
+					actual, err = go2py.Call("go2py_typename", h)
+					So(err, ShouldBeNil)
+					So(actual, ShouldEqual, "FailureTest")
+				})
+			})
+
+			Convey("Then the handle should not be used after it's released", func() {
+				So(ReleaseHandle(h), ShouldBeNil)
+				_, err := go2py.Call("go2py_typename", h)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "released")
+				So(ReleaseHandle(h), ShouldNotBeNil)
+			})
+		})
+
+		Convey("When calling a function with KeepResult", func() {
+			opts := &ConvertOptions{KeepResult: true}
+			h, err := go2py.CallWithOptions(opts, "go2py_identity", data.Array{data.Int(1)})
+			So(err, ShouldBeNil)
+			Reset(func() {
+				ReleaseHandle(h)
+			})
+
+			Convey("Then the return value should be kept as a handle", func() {
+				So(IsHandle(h), ShouldBeTrue)
+				actual, err := go2py.Call("go2py_identity", h)
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, data.Array{data.Int(1)})
+			})
+		})
+
+		Convey("When getting an attribute having a time with KeepResult", func() {
+			opts := &ConvertOptions{KeepResult: true}
+			v, err := py2go.GetAttrWithOptions(opts, "time_value")
+
+			Convey("Then the time should be converted as a value", func() {
+				So(err, ShouldBeNil)
+				So(IsHandle(v), ShouldBeFalse)
+				So(v, ShouldEqual, "14:27:00.500000")
+			})
+		})
+
+		Convey("When a handle isn't used for its TTL", func() {
+			opts := &ConvertOptions{KeepResult: true, HandleTTL: 10 * time.Millisecond}
+			h, err := go2py.CallWithOptions(opts, "go2py_identity", data.Int(1))
+			So(err, ShouldBeNil)
+			time.Sleep(20 * time.Millisecond)
+
+			Convey("Then it should be expired", func() {
+				_, err := go2py.Call("go2py_identity", h)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "expired")
+			})
+
+			Convey("Then it should be released without being used", func() {
+				time.Sleep(handleSweepInterval + 100*time.Millisecond)
+				id, _ := getHandleID(h)
+				var ok bool
+				mainthread.ExecSync(func() {
+					_, ok = handles[id]
+				})
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("When creating handles", func() {
+			opts := &ConvertOptions{KeepResult: true}
+			h1, err := go2py.CallWithOptions(opts, "go2py_identity", data.Int(1))
+			So(err, ShouldBeNil)
+			Reset(func() {
+				ReleaseHandle(h1)
+			})
+			h2, err := go2py.CallWithOptions(opts, "go2py_identity", data.Int(2))
+			So(err, ShouldBeNil)
+			Reset(func() {
+				ReleaseHandle(h2)
+			})
+
+			Convey("Then their IDs should not be predictable from each other", func() {
+				id1, _ := getHandleID(h1)
+				id2, _ := getHandleID(h2)
+				So(id1, ShouldBeGreaterThan, 0)
+				So(id2, ShouldBeGreaterThan, 0)
+				So(id2-id1, ShouldNotEqual, 1)
+			})
+		})
+
+		Convey("When creating handles with an owner", func() {
+			owner := &struct{ name string }{"owner"}
+			opts := &ConvertOptions{KeepResult: true, HandleOwner: owner}
+			h1, err := go2py.CallWithOptions(opts, "go2py_identity", data.Int(1))
+			So(err, ShouldBeNil)
+			h2, err := go2py.CallWithOptions(opts, "go2py_identity", data.Int(2))
+			So(err, ShouldBeNil)
+			h3, err := go2py.CallWithOptions(&ConvertOptions{KeepResult: true},
+				"go2py_identity", data.Int(3))
+			So(err, ShouldBeNil)
+			Reset(func() {
+				ReleaseHandle(h3)
+			})
+
+			Convey("Then ReleaseHandles should only release handles of the owner", func() {
+				So(ReleaseHandles(owner), ShouldEqual, 2)
+				So(ReleaseHandle(h1), ShouldNotBeNil)
+				So(ReleaseHandle(h2), ShouldNotBeNil)
+				actual, err := go2py.Call("go2py_identity", h3)
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When releasing a value which isn't a handle", func() {
+			err := ReleaseHandle(data.Map{"a": data.Int(1)})
+
+			Convey("Then it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
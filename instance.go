@@ -16,8 +16,7 @@ type ObjectInstance struct {
 	Object
 }
 
-// Call calls `name` function.
-// [TODO] this function is not supported named arguments
+// Call calls `name` function. Use CallKw to pass keyword arguments.
 func (ins *ObjectInstance) Call(name string, args ...data.Value) (data.Value,
 	error) {
 	type Result struct {
@@ -26,17 +25,135 @@ func (ins *ObjectInstance) Call(name string, args ...data.Value) (data.Value,
 	}
 	ch := make(chan *Result)
 	mainthread.Exec(func() {
-		if ins.p == nil {
-			ch <- &Result{nil, fmt.Errorf("ins.p of %p is nil while calling %s", ins, name)}
-			return
-		}
-		v, err := invoke(ins.p, name, args, nil)
+		v, err := ins.call(name, args, nil, nil, nil)
+		ch <- &Result{v, err}
+	})
+	res := <-ch
+	return res.val, res.err
+}
+
+// CallWithOptions calls `name` function like Call. Arguments and the return
+// value are converted with opts. When opts is nil, the default options are
+// used.
+func (ins *ObjectInstance) CallWithOptions(opts *ConvertOptions, name string,
+	args ...data.Value) (data.Value, error) {
+	type Result struct {
+		val data.Value
+		err error
+	}
+	ch := make(chan *Result)
+	mainthread.Exec(func() {
+		v, err := ins.call(name, args, nil, opts, nil)
 		ch <- &Result{v, err}
 	})
 	res := <-ch
 	return res.val, res.err
 }
 
+// CallKw calls `name` function with positional arguments and keyword
+// arguments. kwargs can be nil when the function doesn't need keyword
+// arguments.
+func (ins *ObjectInstance) CallKw(name string, args []data.Value, kwargs data.Map) (
+	data.Value, error) {
+	return ins.CallKwWithOptions(nil, name, args, kwargs)
+}
+
+// CallKwWithOptions calls `name` function like CallKw. Arguments and the
+// return value are converted with opts. When opts is nil, the default options
+// are used.
+func (ins *ObjectInstance) CallKwWithOptions(opts *ConvertOptions, name string,
+	args []data.Value, kwargs data.Map) (data.Value, error) {
+	return ins.CallKwWithContext(nil, opts, name, args, kwargs)
+}
+
+// CallKwWithContext calls `name` function like CallKwWithOptions. cc is
+// provided to the "sensorbee" Python module while the function is running.
+// See CallContext for details.
+func (ins *ObjectInstance) CallKwWithContext(cc *CallContext, opts *ConvertOptions,
+	name string, args []data.Value, kwargs data.Map) (data.Value, error) {
+	type Result struct {
+		val data.Value
+		err error
+	}
+	ch := make(chan *Result)
+	mainthread.Exec(func() {
+		v, err := ins.call(name, args, kwargs, opts, cc)
+		ch <- &Result{v, err}
+	})
+	res := <-ch
+	return res.val, res.err
+}
+
+// CallBatch calls `name` function with each argument list in argsList. All
+// calls, including conversions of arguments and return values, are done in
+// a single acquisition of the GIL, which is much more efficient than calling
+// Call for each argument list. It returns the return values and the errors of
+// calls in the same order as argsList. When the i-th call fails, errs[i] has
+// the error and vals[i] is nil. A failure of a call doesn't stop other calls.
+func (ins *ObjectInstance) CallBatch(name string, argsList [][]data.Value) (
+	vals []data.Value, errs []error) {
+	return ins.CallBatchWithOptions(nil, name, argsList)
+}
+
+// CallBatchWithOptions calls `name` function like CallBatch. Arguments and
+// return values are converted with opts. When opts is nil, the default
+// options are used.
+func (ins *ObjectInstance) CallBatchWithOptions(opts *ConvertOptions, name string,
+	argsList [][]data.Value) (vals []data.Value, errs []error) {
+	return ins.CallBatchWithContext(nil, opts, name, argsList)
+}
+
+// CallBatchWithContext calls `name` function like CallBatchWithOptions. cc is
+// provided to the "sensorbee" Python module while each call is running. See
+// CallContext for details.
+func (ins *ObjectInstance) CallBatchWithContext(cc *CallContext, opts *ConvertOptions,
+	name string, argsList [][]data.Value) (vals []data.Value, errs []error) {
+	ch := make(chan struct{})
+	mainthread.Exec(func() {
+		defer close(ch)
+		vals, errs = ins.callBatch(name, argsList, getConvertOptions(opts), cc)
+	})
+	<-ch
+	return
+}
+
+func (ins *ObjectInstance) callBatch(name string, argsList [][]data.Value,
+	opts *ConvertOptions, cc *CallContext) ([]data.Value, []error) {
+	vals := make([]data.Value, len(argsList))
+	errs := make([]error, len(argsList))
+	fail := func(err error) ([]data.Value, []error) {
+		for i := range errs {
+			errs[i] = err
+		}
+		return vals, errs
+	}
+
+	if ins.p == nil {
+		return fail(fmt.Errorf("ins.p of %p is nil while calling %s", ins, name))
+	}
+	f, err := getPyFunc(ins.p, name)
+	if err != nil {
+		return fail(fmt.Errorf("fail to get '%v' function: %w", name, err))
+	}
+	defer f.decRef()
+
+	for i, args := range argsList {
+		vals[i], errs[i] = f.invoke(args, nil, opts, cc)
+		if errs[i] != nil {
+			vals[i] = nil
+		}
+	}
+	return vals, errs
+}
+
+func (ins *ObjectInstance) call(name string, args []data.Value, kwdArgs data.Map,
+	opts *ConvertOptions, cc *CallContext) (data.Value, error) {
+	if ins.p == nil {
+		return nil, fmt.Errorf("ins.p of %p is nil while calling %s", ins, name)
+	}
+	return invoke(ins.p, name, args, kwdArgs, getConvertOptions(opts), cc)
+}
+
 // CheckFunc checks if function having the name exists. It returns true when the
 // function is found.
 func (ins *ObjectInstance) CheckFunc(name string) bool {
@@ -62,21 +179,29 @@ func (ins *ObjectInstance) CheckFunc(name string) bool {
 // returned.
 func (ins *ObjectInstance) CallDirect(name string, args []data.Value,
 	kwdArg data.Map) (Object, error) {
+	return ins.CallDirectWithContext(nil, name, args, kwdArg)
+}
+
+// CallDirectWithContext calls `name` function like CallDirect. cc is provided
+// to the "sensorbee" Python module while the function is running. See
+// CallContext for details.
+func (ins *ObjectInstance) CallDirectWithContext(cc *CallContext, name string,
+	args []data.Value, kwdArg data.Map) (Object, error) {
 	type Result struct {
 		val Object
 		err error
 	}
 	ch := make(chan *Result)
 	mainthread.Exec(func() {
-		v, err := invokeDirect(ins.p, name, args, kwdArg)
+		v, err := invokeDirect(ins.p, name, args, kwdArg, &defaultConvertOptions, cc)
 		ch <- &Result{v, err}
 	})
 	res := <-ch
 	return res.val, res.err
 }
 
-func newInstance(m *ObjectModule, name string, args []data.Value, kwdArgs data.Map) (
-	result ObjectInstance, resErr error) {
+func newInstance(m *ObjectModule, name string, args []data.Value, kwdArgs data.Map,
+	opts *ConvertOptions) (result ObjectInstance, resErr error) {
 
 	cName := C.CString(name)
 	defer C.free(unsafe.Pointer(cName))
@@ -89,12 +214,12 @@ func newInstance(m *ObjectModule, name string, args []data.Value, kwdArgs data.M
 
 	pyInstance := C.PyObject_GetAttrString(m.p, cName)
 	if pyInstance == nil {
-		return ObjectInstance{}, fmt.Errorf("fail to get '%v' class: %v", name, getPyErr())
+		return ObjectInstance{}, fmt.Errorf("fail to get '%v' class: %w", name, getPyErr())
 	}
 	defer C.Py_DecRef(pyInstance)
 
 	// no named arguments
-	pyArg, err := convertArgsGo2Py(args)
+	pyArg, err := convertArgsGo2Py(args, opts)
 	if err != nil {
 		return ObjectInstance{}, fmt.Errorf("fail to convert non named arguments in creating '%v' instance: %v",
 			name, err.Error())
@@ -106,7 +231,7 @@ func newInstance(m *ObjectModule, name string, args []data.Value, kwdArgs data.M
 	if len(kwdArgs) == 0 {
 		pyKwdArg = nil
 	} else {
-		o, err := newPyObj(kwdArgs)
+		o, err := newPyObj(kwdArgs, opts)
 		if err != nil {
 			return ObjectInstance{}, fmt.Errorf("fail to convert named arguments in creating '%v' instance: %v",
 				name, err.Error())
@@ -117,7 +242,7 @@ func newInstance(m *ObjectModule, name string, args []data.Value, kwdArgs data.M
 
 	ret := C.PyObject_Call(pyInstance, pyArg.p, pyKwdArg)
 	if ret == nil {
-		return ObjectInstance{}, fmt.Errorf("fail to create '%v' instance: %v", name, getPyErr())
+		return ObjectInstance{}, fmt.Errorf("fail to create '%v' instance: %w", name, getPyErr())
 	}
 
 	return ObjectInstance{Object{p: ret}}, nil
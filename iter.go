@@ -0,0 +1,166 @@
+package py
+
+/*
+#include "Python.h"
+*/
+import "C"
+import (
+	"fmt"
+
+	"gopkg.in/sensorbee/py.v0/mainthread"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+// ObjectIter is a bind of a Python iterator such as a generator. Values are
+// converted one by one when Next is called, so a large result can be consumed
+// lazily. Close must be called after the iterator is used.
+type ObjectIter struct {
+	Object
+
+	name string
+	opts *ConvertOptions
+	cc   *CallContext
+}
+
+// CallIter calls `name` function and returns an iterator of its return value.
+// The return value can be any iterable object such as a generator or a list.
+func (ins *ObjectInstance) CallIter(name string, args ...data.Value) (*ObjectIter, error) {
+	return ins.CallIterWithOptions(nil, name, args...)
+}
+
+// CallIterWithOptions calls `name` function like CallIter. Arguments and
+// values returned from the iterator are converted with opts. When opts is nil,
+// the default options are used.
+func (ins *ObjectInstance) CallIterWithOptions(opts *ConvertOptions, name string,
+	args ...data.Value) (*ObjectIter, error) {
+	return execCallIter(&ins.Object, name, args, opts, nil)
+}
+
+// CallIterWithContext calls `name` function like CallIterWithOptions. cc is
+// provided to the "sensorbee" Python module while the function is running and
+// while the iterator returns values. See CallContext for details.
+func (ins *ObjectInstance) CallIterWithContext(cc *CallContext, opts *ConvertOptions,
+	name string, args ...data.Value) (*ObjectIter, error) {
+	return execCallIter(&ins.Object, name, args, opts, cc)
+}
+
+// CallIter calls `name` function of the module and returns an iterator of its
+// return value like ObjectInstance.CallIter.
+func (m *ObjectModule) CallIter(name string, args ...data.Value) (*ObjectIter, error) {
+	return m.CallIterWithOptions(nil, name, args...)
+}
+
+// CallIterWithOptions calls `name` function like CallIter. Arguments and
+// values returned from the iterator are converted with opts. When opts is nil,
+// the default options are used.
+func (m *ObjectModule) CallIterWithOptions(opts *ConvertOptions, name string,
+	args ...data.Value) (*ObjectIter, error) {
+	return execCallIter(&m.Object, name, args, opts, nil)
+}
+
+// CallIterWithContext calls `name` function like CallIterWithOptions. cc is
+// provided to the "sensorbee" Python module while the function is running and
+// while the iterator returns values. See CallContext for details.
+func (m *ObjectModule) CallIterWithContext(cc *CallContext, opts *ConvertOptions,
+	name string, args ...data.Value) (*ObjectIter, error) {
+	return execCallIter(&m.Object, name, args, opts, cc)
+}
+
+func execCallIter(o *Object, name string, args []data.Value, opts *ConvertOptions,
+	cc *CallContext) (*ObjectIter, error) {
+	type Result struct {
+		val *ObjectIter
+		err error
+	}
+	ch := make(chan *Result)
+	mainthread.Exec(func() {
+		it, err := callIter(o, name, args, getConvertOptions(opts), cc)
+		ch <- &Result{it, err}
+	})
+	res := <-ch
+	return res.val, res.err
+}
+
+func callIter(o *Object, name string, args []data.Value, opts *ConvertOptions,
+	cc *CallContext) (*ObjectIter, error) {
+	if o.p == nil {
+		return nil, fmt.Errorf("o.p of %p is nil while calling %s", o, name)
+	}
+	ret, err := invokeDirect(o.p, name, args, nil, opts, cc)
+	if err != nil {
+		return nil, err
+	}
+	defer ret.decRef()
+
+	it := C.PyObject_GetIter(ret.p)
+	if it == nil {
+		return nil, fmt.Errorf("'%v' didn't return an iterable object: %w", name, getPyErr())
+	}
+	return &ObjectIter{
+		Object: Object{p: it},
+		name:   name,
+		opts:   opts,
+		cc:     cc,
+	}, nil
+}
+
+// Next returns the next value of the iterator. It returns false when the
+// iterator doesn't have any more values or it's already closed. An error
+// raised by the iterator is returned as an error.
+func (it *ObjectIter) Next() (data.Value, bool, error) {
+	type Result struct {
+		val data.Value
+		ok  bool
+		err error
+	}
+	ch := make(chan *Result)
+	mainthread.Exec(func() {
+		v, ok, err := it.next()
+		ch <- &Result{v, ok, err}
+	})
+	res := <-ch
+	return res.val, res.ok, res.err
+}
+
+func (it *ObjectIter) next() (data.Value, bool, error) {
+	if it.p == nil {
+		return nil, false, nil
+	}
+	defer enterCallContext(it.cc, it.opts, it.name)()
+	o := C.PyIter_Next(it.p)
+	if o == nil {
+		if C.PyErr_Occurred() != nil {
+			return nil, false, getPyErr()
+		}
+		return nil, false, nil
+	}
+	defer C.Py_DecRef(o)
+
+	if it.opts.KeepResult {
+		return newPyHandle(o, it.opts), true, nil
+	}
+	v, err := fromPyTypeObject(o, it.opts)
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+// Close closes the iterator. When the iterator is a generator, its `close`
+// method is called so that `finally` clauses in the generator are executed.
+// Errors raised while closing the generator are ignored. A user can safely
+// call this method more than once.
+func (it *ObjectIter) Close() {
+	mainthread.ExecSync(func() {
+		if it.p == nil {
+			return
+		}
+		if hasPyAttr(it.p, "close") {
+			if ret, err := invokeDirect(it.p, "close", nil, nil, it.opts, it.cc); err == nil {
+				ret.decRef()
+			}
+		}
+		C.Py_DecRef(it.p)
+		it.p = nil
+	})
+}
@@ -0,0 +1,124 @@
+package py
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/py.v0/mainthread"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+func TestCallIter(t *testing.T) {
+	Convey("Given an initialized python module", t, func() {
+
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_new_instance")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		ins, err := mdl.NewInstance("PythonTestForIter", nil, nil)
+		So(err, ShouldBeNil)
+		Reset(func() {
+			ins.Release()
+		})
+
+		Convey("When iterating a generator", func() {
+			it, err := ins.CallIter("count", data.Int(3))
+			So(err, ShouldBeNil)
+			Reset(func() {
+				it.Close()
+			})
+
+			Convey("Then it should return all values", func() {
+				for i := 0; i < 3; i++ {
+					v, ok, err := it.Next()
+					So(err, ShouldBeNil)
+					So(ok, ShouldBeTrue)
+					So(v, ShouldEqual, i)
+				}
+				_, ok, err := it.Next()
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeFalse)
+			})
+
+			Convey("Then closing it before the end should close the generator", func() {
+				_, ok, err := it.Next()
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+				it.Close()
+
+				closed, err := ins.GetAttr("closed")
+				So(err, ShouldBeNil)
+				So(closed, ShouldEqual, data.True)
+				_, ok, err = it.Next()
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("When iterating a generator raising an error", func() {
+			it, err := ins.CallIter("fail")
+			So(err, ShouldBeNil)
+			Reset(func() {
+				it.Close()
+			})
+
+			Convey("Then the error should be returned", func() {
+				v, ok, err := it.Next()
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+				So(v, ShouldEqual, 1)
+
+				_, ok, err = it.Next()
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "iter error")
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("When iterating a list", func() {
+			it, err := ins.CallIter("values")
+			So(err, ShouldBeNil)
+			Reset(func() {
+				it.Close()
+			})
+
+			Convey("Then it should return its elements", func() {
+				v, _, err := it.Next()
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 1)
+				v, _, err = it.Next()
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, "a")
+			})
+		})
+
+		Convey("When calling a function returning a non-iterable object", func() {
+			_, err := ins.CallIter("not_iterable")
+
+			Convey("Then it should fail", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "iterable")
+			})
+		})
+
+		Convey("When iterating a generator of a module function", func() {
+			it, err := mdl.CallIter("count", data.Int(2))
+			So(err, ShouldBeNil)
+			Reset(func() {
+				it.Close()
+			})
+
+			Convey("Then it should return converted values", func() {
+				v, ok, err := it.Next()
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+				So(v, ShouldResemble, data.Map{"i": data.Int(0)})
+			})
+		})
+	})
+}
@@ -1,12 +1,16 @@
 package mainthread
 
 /*
+#include <pthread.h>
 #include "Python.h"
 */
 import "C"
 
 var (
 	jobs = make(chan func())
+
+	// mainThread is the OS thread running the Python interpreter.
+	mainThread C.pthread_t
 )
 
 // Exec asynchronously executes a function on the main thread. Callers generally
@@ -62,6 +66,13 @@ func process() {
 	}
 }
 
+// IsMainThread returns true when it's called on the main thread, that is, in
+// a function passed to Exec or in Go code called back from Python. Functions
+// having a "NoGIL" suffix must only be called on the main thread.
+func IsMainThread() bool {
+	return C.pthread_equal(C.pthread_self(), mainThread) != 0
+}
+
 // Terminate terminates the main thread. After calling this function, Exec,
 // ExecSync, and other Python modules for SensorBee will no longer work.
 // This function is basically provided for debugging purpose. More specifically,
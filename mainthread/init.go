@@ -10,6 +10,7 @@ this package is executed before all other init functions in py package.
 package mainthread
 
 /*
+#include <pthread.h>
 #include "Python.h"
 */
 import "C"
@@ -23,6 +24,7 @@ func init() {
 	go func() {
 		// Python interpreter needs to run on the same OS thread.
 		runtime.LockOSThread()
+		mainThread = C.pthread_self()
 		if C.Py_IsInitialized() != 0 {
 			ch <- errors.New("python has already been initialized by another module" +
 				" but sensorbee/py needs to initialize python by itself to keep using the same main thread")
@@ -2,6 +2,10 @@ package py
 
 /*
 #include "Python.h"
+
+static PyObject* compileModuleSource(const char* source, const char* filename) {
+  return Py_CompileString(source, filename, Py_file_input);
+}
 */
 import "C"
 import (
@@ -17,7 +21,9 @@ type ObjectModule struct {
 }
 
 // LoadModule loads `name` module. The module needs to be placed at `sys.path`.
-// User can set optional `sys.path` using `mainthread.AppendSysPath`
+// User can set optional `sys.path` using `mainthread.AppendSysPath`. A path of
+// a zip archive or a wheel can also be added to `sys.path`, and modules in it
+// are loaded by zipimport.
 func LoadModule(name string) (ObjectModule, error) {
 	cModule := C.CString(name)
 	defer C.free(unsafe.Pointer(cModule))
@@ -31,7 +37,7 @@ func LoadModule(name string) (ObjectModule, error) {
 		pyMdl := C.PyImport_ImportModule(cModule)
 		if pyMdl == nil {
 			ch <- &Result{ObjectModule{}, fmt.Errorf(
-				"fail to load '%v' module: %v", name, getPyErr())}
+				"fail to load '%v' module: %w", name, getPyErr())}
 			return
 		}
 
@@ -42,6 +48,47 @@ func LoadModule(name string) (ObjectModule, error) {
 	return res.val, res.err
 }
 
+// LoadModuleFromSource compiles source and creates `name` module from it
+// without a file. The module is registered to `sys.modules` so that other
+// modules can import it. When a module having the same name is already loaded,
+// the module is executed again with the new source.
+func LoadModuleFromSource(name, source string) (ObjectModule, error) {
+	type Result struct {
+		val ObjectModule
+		err error
+	}
+	ch := make(chan *Result)
+	mainthread.Exec(func() {
+		m, err := loadModuleFromSource(name, source)
+		ch <- &Result{m, err}
+	})
+	res := <-ch
+	return res.val, res.err
+}
+
+func loadModuleFromSource(name, source string) (ObjectModule, error) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	cSource := C.CString(source)
+	defer C.free(unsafe.Pointer(cSource))
+	cFilename := C.CString(fmt.Sprintf("<%v>", name))
+	defer C.free(unsafe.Pointer(cFilename))
+
+	code := C.compileModuleSource(cSource, cFilename)
+	if code == nil {
+		return ObjectModule{}, fmt.Errorf("fail to compile '%v' module: %w",
+			name, getPyErr())
+	}
+	defer C.Py_DecRef(code)
+
+	pyMdl := C.PyImport_ExecCodeModuleEx(cName, code, cFilename)
+	if pyMdl == nil {
+		return ObjectModule{}, fmt.Errorf("fail to load '%v' module: %w",
+			name, getPyErr())
+	}
+	return ObjectModule{Object{p: pyMdl}}, nil
+}
+
 // NewInstance returns 'name' constructor with named arguments.
 //
 //  class Sample(object):
@@ -83,7 +130,7 @@ func (m *ObjectModule) NewInstance(name string, args []data.Value, kwdArgs data.
 	}
 	ch := make(chan *Result)
 	mainthread.Exec(func() {
-		r, err := newInstance(m, name, args, kwdArgs)
+		r, err := newInstance(m, name, args, kwdArgs, &defaultConvertOptions)
 		ch <- &Result{r, err}
 	})
 	res := <-ch
@@ -105,7 +152,7 @@ func (m *ObjectModule) GetClass(name string) (ObjectInstance, error) {
 		pyInstance := C.PyObject_GetAttrString(m.p, cName)
 		if pyInstance == nil {
 			ch <- &Result{ObjectInstance{}, fmt.Errorf(
-				"fail to get '%v' instance: %v", name, getPyErr())}
+				"fail to get '%v' instance: %w", name, getPyErr())}
 			return
 		}
 		ch <- &Result{ObjectInstance{Object{p: pyInstance}}, nil}
@@ -124,9 +171,83 @@ func (m *ObjectModule) Call(name string, args ...data.Value) (data.Value, error)
 	}
 	ch := make(chan *Result)
 	mainthread.Exec(func() {
-		v, err := invoke(m.p, name, args, nil)
+		v, err := invoke(m.p, name, args, nil, &defaultConvertOptions, nil)
 		ch <- &Result{v, err}
 	})
 	res := <-ch
 	return res.val, res.err
 }
+
+// CallWithOptions calls `name` function like Call. Arguments and the return
+// value are converted with opts. When opts is nil, the default options are
+// used.
+func (m *ObjectModule) CallWithOptions(opts *ConvertOptions, name string,
+	args ...data.Value) (data.Value, error) {
+	type Result struct {
+		val data.Value
+		err error
+	}
+	ch := make(chan *Result)
+	mainthread.Exec(func() {
+		v, err := invoke(m.p, name, args, nil, getConvertOptions(opts), nil)
+		ch <- &Result{v, err}
+	})
+	res := <-ch
+	return res.val, res.err
+}
+
+// CallKw calls `name` function with positional arguments and keyword
+// arguments. kwargs can be nil when the function doesn't need keyword
+// arguments.
+func (m *ObjectModule) CallKw(name string, args []data.Value, kwargs data.Map) (
+	data.Value, error) {
+	return m.CallKwWithOptions(nil, name, args, kwargs)
+}
+
+// CallKwWithOptions calls `name` function like CallKw. Arguments and the
+// return value are converted with opts. When opts is nil, the default options
+// are used.
+func (m *ObjectModule) CallKwWithOptions(opts *ConvertOptions, name string,
+	args []data.Value, kwargs data.Map) (data.Value, error) {
+	return m.CallKwWithContext(nil, opts, name, args, kwargs)
+}
+
+// CallKwWithContext calls `name` function like CallKwWithOptions. cc is
+// provided to the "sensorbee" Python module while the function is running.
+// See CallContext for details.
+func (m *ObjectModule) CallKwWithContext(cc *CallContext, opts *ConvertOptions,
+	name string, args []data.Value, kwargs data.Map) (data.Value, error) {
+	type Result struct {
+		val data.Value
+		err error
+	}
+	ch := make(chan *Result)
+	mainthread.Exec(func() {
+		v, err := invoke(m.p, name, args, kwargs, getConvertOptions(opts), cc)
+		ch <- &Result{v, err}
+	})
+	res := <-ch
+	return res.val, res.err
+}
+
+// Reload reloads the module like `importlib.reload`. The module must have been
+// loaded by LoadModule. Instances created before reloading the module keep
+// using the old classes.
+func (m *ObjectModule) Reload() error {
+	ch := make(chan error)
+	mainthread.Exec(func() {
+		if m.p == nil {
+			ch <- fmt.Errorf("m.p of %p is nil while reloading", m)
+			return
+		}
+		p := C.PyImport_ReloadModule(m.p)
+		if p == nil {
+			ch <- fmt.Errorf("fail to reload module: %w", getPyErr())
+			return
+		}
+		C.Py_DecRef(m.p)
+		m.p = p
+		ch <- nil
+	})
+	return <-ch
+}
@@ -1,10 +1,16 @@
 package py
 
 import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 	"gopkg.in/sensorbee/py.v0/mainthread"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
 	"gopkg.in/sensorbee/sensorbee.v0/data"
 )
 
@@ -31,6 +37,75 @@ func TestLoadModuleError(t *testing.T) {
 	})
 }
 
+func TestLoadModuleFromSource(t *testing.T) {
+	Convey("Given a python interpreter", t, func() {
+		Convey("When loading a module from source", func() {
+			mdl, err := LoadModuleFromSource("_test_inline_module",
+				"def twice(x):\n    return x * 2\n")
+			So(err, ShouldBeNil)
+			Reset(func() {
+				mdl.Release()
+			})
+
+			Convey("Then its function should be callable", func() {
+				v, err := mdl.Call("twice", data.Int(2))
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 4)
+			})
+
+			Convey("Then it should be imported by LoadModule", func() {
+				m, err := LoadModule("_test_inline_module")
+				So(err, ShouldBeNil)
+				defer m.Release()
+				v, err := m.Call("twice", data.String("a"))
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, "aa")
+			})
+		})
+
+		Convey("When loading a module from invalid source", func() {
+			_, err := LoadModuleFromSource("_test_inline_error", "def f(:\n")
+			Convey("Then an error should be occurred", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "SyntaxError")
+			})
+		})
+	})
+}
+
+func TestLoadModuleFromZip(t *testing.T) {
+	Convey("Given a zip archive having a module", t, func() {
+		dir, err := ioutil.TempDir("", "sensorbee_py_test")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		path := filepath.Join(dir, "modules.zip")
+		f, err := os.Create(path)
+		So(err, ShouldBeNil)
+		w := zip.NewWriter(f)
+		mw, err := w.Create("_test_zipped_module.py")
+		So(err, ShouldBeNil)
+		_, err = mw.Write([]byte("def hello():\n    return 'zipped'\n"))
+		So(err, ShouldBeNil)
+		So(w.Close(), ShouldBeNil)
+		So(f.Close(), ShouldBeNil)
+
+		Convey("When the archive is added to sys.path", func() {
+			So(mainthread.AppendSysPath(path), ShouldBeNil)
+
+			Convey("Then the module should be loaded", func() {
+				mdl, err := LoadModule("_test_zipped_module")
+				So(err, ShouldBeNil)
+				defer mdl.Release()
+				v, err := mdl.Call("hello")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, "zipped")
+			})
+		})
+	})
+}
+
 func TestNewInstanceAndStateness(t *testing.T) {
 	Convey("Given an initialized python module", t, func() {
 
@@ -304,3 +379,272 @@ func TestNewInstanceWithKeywordArgument(t *testing.T) {
 		})
 	})
 }
+
+func TestCallBatch(t *testing.T) {
+	Convey("Given an initialized python instance", t, func() {
+
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_new_instance")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		ins, err := mdl.NewInstance("PythonTestForBatch", nil, nil)
+		So(err, ShouldBeNil)
+		Reset(func() {
+			ins.Release()
+		})
+
+		Convey("When calling a method with multiple argument lists", func() {
+			vals, errs := ins.CallBatch("divide", [][]data.Value{
+				{data.Int(6), data.Int(2)},
+				{data.Int(1), data.Int(0)},
+				{data.Int(9), data.Int(3)},
+			})
+
+			Convey("Then each call should have its result", func() {
+				So(len(vals), ShouldEqual, 3)
+				So(len(errs), ShouldEqual, 3)
+				So(errs[0], ShouldBeNil)
+				So(vals[0], ShouldEqual, data.Int(3))
+				So(errs[1], ShouldNotBeNil)
+				So(errs[1].Error(), ShouldContainSubstring, "ZeroDivisionError")
+				So(vals[1], ShouldBeNil)
+				So(errs[2], ShouldBeNil)
+				So(vals[2], ShouldEqual, data.Int(3))
+
+				Convey("And all calls should be done", func() {
+					actual, err := ins.Call("get_count")
+					So(err, ShouldBeNil)
+					So(actual, ShouldEqual, data.Int(3))
+				})
+			})
+		})
+
+		Convey("When calling a method returning an unsupported value", func() {
+			vals, errs := ins.CallBatch("unsupported", [][]data.Value{{}, {}})
+
+			Convey("Then each call should fail", func() {
+				So(vals, ShouldResemble, []data.Value{nil, nil})
+				So(errs[0], ShouldNotBeNil)
+				So(errs[1], ShouldNotBeNil)
+			})
+		})
+
+		Convey("When calling a method which doesn't exist", func() {
+			vals, errs := ins.CallBatch("not_exist", [][]data.Value{{}, {data.Int(1)}})
+
+			Convey("Then all calls should fail", func() {
+				So(len(vals), ShouldEqual, 2)
+				So(errs[0], ShouldNotBeNil)
+				So(errs[0].Error(), ShouldContainSubstring, "not_exist")
+				So(errs[1], ShouldNotBeNil)
+			})
+		})
+
+		Convey("When calling a method with KeepResult", func() {
+			vals, errs := ins.CallBatchWithOptions(&ConvertOptions{KeepResult: true},
+				"unsupported", [][]data.Value{{}, {}})
+			Reset(func() {
+				for _, v := range vals {
+					ReleaseHandle(v)
+				}
+			})
+
+			Convey("Then each result should be kept as a handle", func() {
+				So(errs, ShouldResemble, []error{nil, nil})
+				So(IsHandle(vals[0]), ShouldBeTrue)
+				So(IsHandle(vals[1]), ShouldBeTrue)
+				So(vals[0], ShouldNotResemble, vals[1])
+			})
+		})
+
+		Convey("When calling a method with CallContext", func() {
+			ctx := core.NewContext(nil)
+			vals, errs := ins.CallBatchWithContext(&CallContext{Context: ctx}, nil,
+				"topology", [][]data.Value{{data.String("1")}, {data.String("2")}})
+
+			Convey("Then each call should use the sensorbee module", func() {
+				So(errs, ShouldResemble, []error{nil, nil})
+				So(vals, ShouldResemble, []data.Value{
+					data.String(ctx.TopologyName() + "1"),
+					data.String(ctx.TopologyName() + "2"),
+				})
+			})
+		})
+
+		Convey("When calling a method with no argument list", func() {
+			vals, errs := ins.CallBatch("divide", nil)
+
+			Convey("Then it should return empty results", func() {
+				So(vals, ShouldBeEmpty)
+				So(errs, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestCallKw(t *testing.T) {
+	Convey("Given an initialized python module", t, func() {
+
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_new_instance")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When calling a module function with keyword arguments", func() {
+			actual, err := mdl.CallKw("format_kwd", []data.Value{data.Int(1)},
+				data.Map{"b": data.Int(2), "x": data.Int(3), "y": data.Int(4)})
+
+			Convey("Then the arguments should be passed as keywords", func() {
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "1_2_x,y")
+			})
+		})
+
+		Convey("When calling an instance method with keyword arguments", func() {
+			ins, err := mdl.NewInstance("PythonTestForCallKw", nil, nil)
+			So(err, ShouldBeNil)
+			Reset(func() {
+				ins.Release()
+			})
+
+			Convey("Then the arguments should be passed as keywords", func() {
+				actual, err := ins.CallKw("format_kwd", nil, data.Map{"a": data.Int(1)})
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "1_b_")
+			})
+
+			Convey("Then calling it without keyword arguments should work", func() {
+				actual, err := ins.CallKw("format_kwd", []data.Value{data.Int(1), data.Int(2)}, nil)
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "1_2_")
+			})
+
+			Convey("Then duplicated arguments should fail", func() {
+				_, err := ins.CallKw("format_kwd", []data.Value{data.Int(1)}, data.Map{"a": data.Int(1)})
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "TypeError")
+			})
+		})
+	})
+}
+
+func TestAttr(t *testing.T) {
+	Convey("Given an initialized python module", t, func() {
+
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_new_instance")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When getting a module attribute", func() {
+			actual, err := mdl.GetAttr("module_attr")
+
+			Convey("Then it should return the value", func() {
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "module_value")
+				So(mdl.HasAttr("module_attr"), ShouldBeTrue)
+			})
+		})
+
+		Convey("When setting a module attribute", func() {
+			So(mdl.SetAttr("new_module_attr", data.Array{data.Int(1)}), ShouldBeNil)
+			Reset(func() {
+				So(mdl.SetAttr("new_module_attr", data.Null{}), ShouldBeNil)
+			})
+
+			Convey("Then it should be readable", func() {
+				actual, err := mdl.GetAttr("new_module_attr")
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, data.Array{data.Int(1)})
+			})
+		})
+
+		Convey("When creating an instance", func() {
+			ins, err := mdl.NewInstance("PythonTestForAttr", nil, nil)
+			So(err, ShouldBeNil)
+			Reset(func() {
+				ins.Release()
+			})
+
+			Convey("Then it should have the attribute", func() {
+				So(ins.HasAttr("value"), ShouldBeTrue)
+				So(ins.HasAttr("no_such_attr"), ShouldBeFalse)
+
+				actual, err := ins.GetAttr("value")
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, 1)
+			})
+
+			Convey("Then setting the attribute should be visible to methods", func() {
+				So(ins.SetAttr("value", data.String("a")), ShouldBeNil)
+				actual, err := ins.Call("get_value")
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, "a")
+			})
+
+			Convey("Then getting a missing attribute should fail", func() {
+				_, err := ins.GetAttr("no_such_attr")
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "AttributeError")
+			})
+		})
+	})
+}
+
+func TestReloadModule(t *testing.T) {
+	Convey("Given a module loaded from a file", t, func() {
+		dir, err := ioutil.TempDir("", "sensorbee_py_test")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		path := filepath.Join(dir, "_test_reloaded_module.py")
+		So(ioutil.WriteFile(path, []byte("def version():\n    return 1\n"), 0644), ShouldBeNil)
+		So(mainthread.AppendSysPath(dir), ShouldBeNil)
+
+		mdl, err := LoadModule("_test_reloaded_module")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When the file is modified and the module is reloaded", func() {
+			So(ioutil.WriteFile(path, []byte("def version():\n    return 'two'\n"), 0644), ShouldBeNil)
+			// Python 2 compares mtime in seconds with the cached bytecode.
+			future := time.Now().Add(10 * time.Second)
+			So(os.Chtimes(path, future, future), ShouldBeNil)
+			So(mdl.Reload(), ShouldBeNil)
+
+			Convey("Then the new code should be used", func() {
+				v, err := mdl.Call("version")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, "two")
+			})
+		})
+
+		Convey("When the file is broken and the module is reloaded", func() {
+			So(ioutil.WriteFile(path, []byte("def version(:\n"), 0644), ShouldBeNil)
+			future := time.Now().Add(10 * time.Second)
+			So(os.Chtimes(path, future, future), ShouldBeNil)
+
+			Convey("Then it should fail", func() {
+				err := mdl.Reload()
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "SyntaxError")
+			})
+		})
+	})
+}
@@ -0,0 +1,255 @@
+package py
+
+/*
+#include "Python.h"
+
+int HasArrayInterface(PyObject* o) {
+  return PyObject_HasAttrString(o, "__array_interface__");
+}
+
+int GetPyBuffer(PyObject* o, Py_buffer* view) {
+  return PyObject_GetBuffer(o, view, PyBUF_RECORDS_RO);
+}
+*/
+import "C"
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"unsafe"
+
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+// numpyModule is the numpy module imported when it's required for the first
+// time. NumPy is an optional dependency, so it isn't imported at
+// initialization.
+var numpyModule Object
+
+// fromNumPyObject converts a NumPy array or a NumPy scalar. Objects having
+// `__array_interface__` are regarded as NumPy objects. A C-contiguous or
+// strided array of numbers is read through the buffer protocol. Other objects
+// are converted with their `tolist` method, which returns a Python scalar for
+// a NumPy scalar. It returns false when o isn't a NumPy object.
+func fromNumPyObject(o *C.PyObject, opts *ConvertOptions) (data.Value, bool, error) {
+	if C.HasArrayInterface(o) == 0 {
+		return nil, false, nil
+	}
+
+	// Py_buffer is allocated in C because it's filled with C pointers.
+	view := (*C.Py_buffer)(C.malloc(C.sizeof_Py_buffer))
+	defer C.free(unsafe.Pointer(view))
+	if C.GetPyBuffer(o, view) == 0 {
+		defer C.PyBuffer_Release(view)
+		if view.ndim > 0 {
+			if v, ok, err := fromPyBuffer(view, opts); ok {
+				return v, true, err
+			}
+		}
+	} else {
+		C.PyErr_Clear()
+	}
+
+	v, err := invokeNested(o, "tolist", opts)
+	return v, true, err
+}
+
+// fromPyBuffer converts a buffer into nested data.Array. It returns false when
+// the format of the buffer isn't a supported number type in the native byte
+// order.
+func fromPyBuffer(view *C.Py_buffer, opts *ConvertOptions) (data.Value, bool, error) {
+	if view.suboffsets != nil {
+		return nil, false, nil
+	}
+	format := "B"
+	if view.format != nil {
+		format = C.GoString(view.format)
+	}
+	if len(format) == 2 && (format[0] == '@' || format[0] == '=') {
+		format = format[1:]
+	}
+	if len(format) != 1 {
+		return nil, false, nil
+	}
+	read, size := bufferItemReader(format[0], opts)
+	if read == nil || C.Py_ssize_t(size) != view.itemsize {
+		return nil, false, nil
+	}
+
+	ndim := int(view.ndim)
+	shape := (*[1 << 30]C.Py_ssize_t)(unsafe.Pointer(view.shape))[:ndim:ndim]
+	strides := make([]uintptr, ndim)
+	if view.strides != nil {
+		s := (*[1 << 30]C.Py_ssize_t)(unsafe.Pointer(view.strides))[:ndim:ndim]
+		for i := range s {
+			strides[i] = uintptr(s[i])
+		}
+	} else {
+		// C-contiguous
+		stride := uintptr(size)
+		for i := ndim - 1; i >= 0; i-- {
+			strides[i] = stride
+			stride *= uintptr(shape[i])
+		}
+	}
+
+	var walk func(dim int, p unsafe.Pointer) (data.Value, error)
+	walk = func(dim int, p unsafe.Pointer) (data.Value, error) {
+		if dim == ndim {
+			return read(p)
+		}
+		a := make(data.Array, int(shape[dim]))
+		for i := range a {
+			v, err := walk(dim+1, unsafe.Pointer(uintptr(p)+uintptr(i)*strides[dim]))
+			if err != nil {
+				return nil, err
+			}
+			a[i] = v
+		}
+		return a, nil
+	}
+	v, err := walk(0, view.buf)
+	return v, true, err
+}
+
+// bufferItemReader returns a function reading an item of a buffer having
+// the format character and the size of the item. It returns nil when the
+// format isn't supported.
+func bufferItemReader(format byte, opts *ConvertOptions) (
+	func(unsafe.Pointer) (data.Value, error), int) {
+	switch format {
+	case '?':
+		return func(p unsafe.Pointer) (data.Value, error) {
+			return data.Bool(*(*C.uchar)(p) != 0), nil
+		}, 1
+	case 'b':
+		return func(p unsafe.Pointer) (data.Value, error) {
+			return data.Int(*(*C.schar)(p)), nil
+		}, 1
+	case 'B':
+		return func(p unsafe.Pointer) (data.Value, error) {
+			return data.Int(*(*C.uchar)(p)), nil
+		}, 1
+	case 'h':
+		return func(p unsafe.Pointer) (data.Value, error) {
+			return data.Int(*(*C.short)(p)), nil
+		}, C.sizeof_short
+	case 'H':
+		return func(p unsafe.Pointer) (data.Value, error) {
+			return data.Int(*(*C.ushort)(p)), nil
+		}, C.sizeof_short
+	case 'i':
+		return func(p unsafe.Pointer) (data.Value, error) {
+			return data.Int(*(*C.int)(p)), nil
+		}, C.sizeof_int
+	case 'I':
+		return func(p unsafe.Pointer) (data.Value, error) {
+			return data.Int(*(*C.uint)(p)), nil
+		}, C.sizeof_int
+	case 'l':
+		return func(p unsafe.Pointer) (data.Value, error) {
+			return data.Int(*(*C.long)(p)), nil
+		}, C.sizeof_long
+	case 'L':
+		return func(p unsafe.Pointer) (data.Value, error) {
+			return fromUint64(uint64(*(*C.ulong)(p)), opts)
+		}, C.sizeof_long
+	case 'q':
+		return func(p unsafe.Pointer) (data.Value, error) {
+			return data.Int(*(*C.longlong)(p)), nil
+		}, C.sizeof_longlong
+	case 'Q':
+		return func(p unsafe.Pointer) (data.Value, error) {
+			return fromUint64(uint64(*(*C.ulonglong)(p)), opts)
+		}, C.sizeof_longlong
+	case 'f':
+		return func(p unsafe.Pointer) (data.Value, error) {
+			return fromFloat64(float64(*(*C.float)(p)), opts)
+		}, C.sizeof_float
+	case 'd':
+		return func(p unsafe.Pointer) (data.Value, error) {
+			return fromFloat64(float64(*(*C.double)(p)), opts)
+		}, C.sizeof_double
+	}
+	return nil, 0
+}
+
+// fromUint64 converts an unsigned integer into data.Int. When the value
+// doesn't fit in data.Int, it's converted according to opts.IntOverflow.
+func fromUint64(u uint64, opts *ConvertOptions) (data.Value, error) {
+	if u <= math.MaxInt64 {
+		return data.Int(u), nil
+	}
+	switch opts.IntOverflow {
+	case IntOverflowFloat:
+		return data.Float(u), nil
+	case IntOverflowString:
+		return data.String(strconv.FormatUint(u, 10)), nil
+	default:
+		return data.Null{}, fmt.Errorf("python int overflows data.Int: %v", u)
+	}
+}
+
+// isFloatArray returns true when a is a non-empty array only having
+// data.Float.
+func isFloatArray(a data.Array) bool {
+	if len(a) == 0 {
+		return false
+	}
+	for _, v := range a {
+		if v.Type() != data.TypeFloat {
+			return false
+		}
+	}
+	return true
+}
+
+// newPyNDArray creates a one dimensional float64 ndarray from an array of
+// data.Float. It imports numpy when it hasn't been imported yet.
+func newPyNDArray(a data.Array) (*C.PyObject, error) {
+	if numpyModule.p == nil {
+		cName := C.CString("numpy")
+		defer C.free(unsafe.Pointer(cName))
+		m := C.PyImport_ImportModule(cName)
+		if m == nil {
+			return nil, fmt.Errorf("fail to load 'numpy' module: %w", getPyErr())
+		}
+		numpyModule.p = m
+	}
+
+	b := make([]byte, 8*len(a))
+	for i, v := range a {
+		f, _ := data.AsFloat(v)
+		binary.LittleEndian.PutUint64(b[8*i:], math.Float64bits(f))
+	}
+	buf := C.PyByteArray_FromStringAndSize((*C.char)(unsafe.Pointer(&b[0])),
+		C.Py_ssize_t(len(b)))
+	if buf == nil {
+		return nil, getPyErr()
+	}
+	defer C.Py_DecRef(buf)
+
+	frombuffer, err := getPyFunc(numpyModule.p, "frombuffer")
+	if err != nil {
+		return nil, err
+	}
+	defer frombuffer.decRef()
+
+	args := C.PyTuple_New(2)
+	if args == nil {
+		return nil, getPyErr()
+	}
+	defer C.Py_DecRef(args)
+	// PyTuple object takes over the value's reference. bytearray is writable,
+	// so the ndarray created by frombuffer is also writable.
+	C.Py_IncRef(buf)
+	C.PyTuple_SetItem(args, 0, buf)
+	C.PyTuple_SetItem(args, 1, newPyString("<f8"))
+
+	ret, err := frombuffer.callObject(Object{p: args})
+	if err != nil {
+		return nil, err
+	}
+	return ret.p, nil
+}
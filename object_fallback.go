@@ -0,0 +1,159 @@
+package py
+
+/*
+#include "Python.h"
+
+int HasPyAttr(PyObject* o, const char* name) {
+  return PyObject_HasAttrString(o, name);
+}
+
+int IsPyTypeType(PyObject* o) {
+  return PyType_Check(o);
+}
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+var (
+	// dataclassesLoaded is true when the dataclasses module has been tried
+	// to be imported. dataclassesIsDataclass and dataclassesAsDict are nil
+	// when the module isn't available (i.e. Python 3.6 or earlier).
+	dataclassesLoaded      bool
+	dataclassesIsDataclass ObjectFunc
+	dataclassesAsDict      ObjectFunc
+)
+
+// fromPyObjectFallback converts an object which isn't supported by other
+// conversions when opts.ObjectFallback is true. It tries the following
+// conversions in order:
+//
+//  1. the result of the `__sensorbee__()` method of the object
+//  2. `dataclasses.asdict(o)` if the object is an instance of a dataclass
+//  3. the result of the `_asdict()` method of the object
+//  4. `vars(o)`
+//
+// Results are converted recursively. Results of 2, 3, and 4 are converted
+// into data.Map. It returns false when none of them is applicable.
+func fromPyObjectFallback(o *C.PyObject, opts *ConvertOptions) (data.Value, bool, error) {
+	if !opts.ObjectFallback || C.IsPyTypeType(o) > 0 {
+		return nil, false, nil
+	}
+	if err := enterPyObject(o, opts); err != nil {
+		return nil, true, err
+	}
+	defer leavePyObject(o)
+
+	if hasPyAttr(o, "__sensorbee__") {
+		v, err := invokeNested(o, "__sensorbee__", opts)
+		return v, true, err
+	}
+
+	if ok, err := isDataclassInstance(o); err != nil {
+		return nil, true, err
+	} else if ok {
+		d, err := dataclassesAsDict.callObject1(o)
+		if err != nil {
+			return nil, true, fmt.Errorf("fail to call dataclasses.asdict: %v", err)
+		}
+		defer d.decRef()
+		v, err := fromPyObjectDict(d.p, opts)
+		return v, true, err
+	}
+
+	if hasPyAttr(o, "_asdict") {
+		d, err := invokeDirect(o, "_asdict", nil, nil, opts, nil)
+		if err != nil {
+			return nil, true, err
+		}
+		defer d.decRef()
+		v, err := fromPyObjectDict(d.p, opts)
+		return v, true, err
+	}
+
+	if hasPyAttr(o, "__dict__") {
+		// equivalent to vars(o)
+		cName := C.CString("__dict__")
+		defer C.free(unsafe.Pointer(cName))
+		d := C.PyObject_GetAttrString(o, cName)
+		if d == nil {
+			return nil, true, getPyErr()
+		}
+		defer C.Py_DecRef(d)
+		v, err := fromPyObjectDict(d, opts)
+		return v, true, err
+	}
+	return nil, false, nil
+}
+
+// fromPyObjectDict converts a dict representing an object into data.Map.
+func fromPyObjectDict(d *C.PyObject, opts *ConvertOptions) (data.Value, error) {
+	v, err := fromPyTypeObject(d, opts)
+	if err != nil {
+		return nil, err
+	}
+	if v.Type() != data.TypeMap {
+		return nil, fmt.Errorf("object is converted into %v instead of map", v.Type())
+	}
+	return v, nil
+}
+
+func hasPyAttr(o *C.PyObject, name string) bool {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	return C.HasPyAttr(o, cName) > 0
+}
+
+// isDataclassInstance returns true when o is an instance of a dataclass. It
+// imports dataclasses module when it's called for the first time.
+func isDataclassInstance(o *C.PyObject) (bool, error) {
+	if !dataclassesLoaded {
+		dataclassesLoaded = true
+		m, err := loadModule("dataclasses")
+		if err != nil {
+			C.PyErr_Clear()
+			return false, nil
+		}
+		defer m.decRef()
+		if dataclassesIsDataclass, err = getPyFunc(m.p, "is_dataclass"); err != nil {
+			return false, err
+		}
+		if dataclassesAsDict, err = getPyFunc(m.p, "asdict"); err != nil {
+			return false, err
+		}
+	}
+	if dataclassesIsDataclass.p == nil || dataclassesAsDict.p == nil {
+		return false, nil
+	}
+
+	r, err := dataclassesIsDataclass.callObject1(o)
+	if err != nil {
+		return false, fmt.Errorf("fail to call dataclasses.is_dataclass: %v", err)
+	}
+	defer r.decRef()
+	switch C.PyObject_IsTrue(r.p) {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	default:
+		return false, getPyErr()
+	}
+}
+
+// callObject1 calls the function with one positional argument.
+func (f *ObjectFunc) callObject1(arg *C.PyObject) (Object, error) {
+	args := C.PyTuple_New(1)
+	if args == nil {
+		return Object{}, getPyErr()
+	}
+	defer C.Py_DecRef(args)
+	// PyTuple object takes over the value's reference.
+	C.Py_IncRef(arg)
+	C.PyTuple_SetItem(args, 0, arg)
+	return f.callObject(Object{p: args})
+}
@@ -0,0 +1,294 @@
+package py
+
+import (
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/sensorbee/py.v0/mainthread"
+)
+
+// ConvertOptions has options to control conversions between Python objects
+// and data.Value. The zero value of ConvertOptions keeps the default behavior
+// of this package.
+type ConvertOptions struct {
+	// SortSets sorts values converted from a Python set or frozenset so that
+	// the resulting data.Array has a deterministic order. Values are ordered
+	// by type first (null, bool, number, string, blob, timestamp, array) and
+	// then by value. Maps cannot be ordered and are placed at the end while
+	// keeping their original order. When SortSets is false, the order of
+	// values is the iteration order of the set in Python.
+	SortSets bool
+
+	// IntOverflow decides how to convert a Python int which doesn't fit in
+	// data.Int. See IntOverflowPolicy for details.
+	IntOverflow IntOverflowPolicy
+
+	// Timezone decides which tzinfo a datetime converted from
+	// data.Timestamp has. See TimezonePolicy for details.
+	Timezone TimezonePolicy
+
+	// TimeDelta decides how to convert a Python timedelta. See
+	// TimeDeltaPolicy for details.
+	TimeDelta TimeDeltaPolicy
+
+	// Date decides how to convert a Python date. See DatePolicy for details.
+	Date DatePolicy
+
+	// Time decides how to convert a Python time. See TimePolicy for details.
+	Time TimePolicy
+
+	// MapKey decides how to handle a key of a Python dict which is neither
+	// str nor bytes (or unicode in Python 2). See MapKeyPolicy for details.
+	MapKey MapKeyPolicy
+
+	// NamedTupleAsMap converts a namedtuple into data.Map having its field
+	// names as keys. Otherwise, a namedtuple is converted into data.Array
+	// like other tuples.
+	NamedTupleAsMap bool
+
+	// FloatArrayAsNDArray converts a non-empty data.Array only having
+	// data.Float into a one dimensional float64 ndarray of NumPy instead of
+	// a list. NumPy must be installed to use this option.
+	FloatArrayAsNDArray bool
+
+	// ObjectFallback converts an object which isn't supported otherwise
+	// into data.Map using its `__sensorbee__` method, `dataclasses.asdict`,
+	// its `_asdict` method, or `vars`, which are tried in this order.
+	// `__sensorbee__` can return any value which can be converted into
+	// data.Value. Without this option, such an object cannot be converted.
+	ObjectFallback bool
+
+	// MaxDepth is the maximum depth of nested containers such as lists,
+	// dicts, data.Array, and data.Map. A conversion of a deeper value fails
+	// with an error. DefaultMaxDepth is used when it's 0. A Python object
+	// having a circular reference always fails to be converted regardless of
+	// this option.
+	MaxDepth int
+
+	// Decimal decides how to convert a Python decimal.Decimal or
+	// fractions.Fraction. See DecimalPolicy for details.
+	Decimal DecimalPolicy
+
+	// StringAsDecimal converts data.String representing a finite decimal
+	// number such as "1.50" or "-2e10" into decimal.Decimal instead of str.
+	// Other strings are converted into str as usual.
+	StringAsDecimal bool
+
+	// NonFinite decides how to convert NaN or an infinity returned from
+	// Python as a float. See NonFinitePolicy for details.
+	NonFinite NonFinitePolicy
+
+	// Py2Str decides how to convert a str of Python 2. It doesn't affect
+	// Python 3, which always converts bytes into data.Blob. See Py2StrPolicy
+	// for details.
+	Py2Str Py2StrPolicy
+
+	// HandleUnsupported keeps a Python object which cannot be converted in
+	// the handle table of this package and converts it into a handle instead
+	// of failing. A handle is data.Map only having HandleKey, and it's passed
+	// to Python as the original object. See ReleaseHandle for details.
+	HandleUnsupported bool
+
+	// KeepResult keeps the return value of a function or a method called
+	// with this option as a handle instead of converting it. It doesn't
+	// affect nested values.
+	KeepResult bool
+
+	// HandleTTL is the TTL of a handle created with this option. A handle
+	// which hasn't been used for the TTL is released automatically.
+	// DefaultHandleTTL is used when it's 0.
+	HandleTTL time.Duration
+
+	// HandleOwner is the owner of handles created with this option. All
+	// handles of an owner can be released at once by ReleaseHandles. It must
+	// be comparable. Handles created with nil owner are only released by
+	// ReleaseHandle or their TTL.
+	HandleOwner interface{}
+}
+
+// IntOverflowPolicy is a policy to convert a Python int which doesn't fit in
+// data.Int.
+type IntOverflowPolicy int
+
+const (
+	// IntOverflowError makes the conversion fail with an error.
+	IntOverflowError IntOverflowPolicy = iota
+
+	// IntOverflowFloat converts the value into data.Float. It may lose
+	// precision.
+	IntOverflowFloat
+
+	// IntOverflowString converts the value into data.String having its
+	// decimal representation.
+	IntOverflowString
+)
+
+// TimezonePolicy is a policy to convert data.Timestamp into a Python datetime.
+type TimezonePolicy int
+
+const (
+	// TimezoneNaive converts data.Timestamp into a naive datetime having
+	// the date and the time in the location of the timestamp. The instant
+	// isn't preserved when the location isn't UTC.
+	TimezoneNaive TimezonePolicy = iota
+
+	// TimezoneUTC converts data.Timestamp into a datetime in UTC having
+	// a UTC tzinfo.
+	TimezoneUTC
+
+	// TimezoneOffset converts data.Timestamp into a datetime having a tzinfo
+	// with the fixed UTC offset of the location of the timestamp. Python 3.6
+	// or earlier only supports offsets of whole minutes.
+	TimezoneOffset
+)
+
+// TimeDeltaPolicy is a policy to convert a Python timedelta.
+type TimeDeltaPolicy int
+
+const (
+	// TimeDeltaSeconds converts a timedelta into data.Float having the
+	// duration in seconds.
+	TimeDeltaSeconds TimeDeltaPolicy = iota
+
+	// TimeDeltaMicroseconds converts a timedelta into data.Int having the
+	// duration in microseconds.
+	TimeDeltaMicroseconds
+)
+
+// DatePolicy is a policy to convert a Python date.
+type DatePolicy int
+
+const (
+	// DateTimestamp converts a date into data.Timestamp at midnight in UTC.
+	DateTimestamp DatePolicy = iota
+
+	// DateString converts a date into data.String in the ISO 8601 format such
+	// as "2015-04-01".
+	DateString
+)
+
+// TimePolicy is a policy to convert a Python time.
+type TimePolicy int
+
+const (
+	// TimeString converts a time into data.String returned from its isoformat
+	// method such as "14:27:00.500000".
+	TimeString TimePolicy = iota
+
+	// TimeMap converts a time into data.Map having "hour", "minute",
+	// "second", and "microsecond" as data.Int.
+	TimeMap
+)
+
+// MapKeyPolicy is a policy to convert a key of a Python dict which cannot be
+// a key of data.Map as it is.
+type MapKeyPolicy int
+
+const (
+	// MapKeySkip ignores the key and its value.
+	MapKeySkip MapKeyPolicy = iota
+
+	// MapKeyString converts the key into a string with Python's `str`.
+	MapKeyString
+
+	// MapKeyError makes the conversion fail with an error having the type
+	// name of the key.
+	MapKeyError
+)
+
+// DecimalPolicy is a policy to convert a Python decimal.Decimal or
+// fractions.Fraction.
+type DecimalPolicy int
+
+const (
+	// DecimalFloat converts a value into data.Float. It may lose precision.
+	DecimalFloat DecimalPolicy = iota
+
+	// DecimalString converts a value into data.String returned from Python's
+	// `str` such as "1.50" for a Decimal or "3/4" for a Fraction, which
+	// keeps the exact value.
+	DecimalString
+)
+
+// NonFinitePolicy is a policy to convert NaN, +Inf, or -Inf returned from
+// Python. It's applied to a float, a float converted from a Decimal or a
+// Fraction, and a float in a NumPy array.
+type NonFinitePolicy int
+
+const (
+	// NonFinitePass converts the value into data.Float as it is.
+	NonFinitePass NonFinitePolicy = iota
+
+	// NonFiniteNull converts the value into data.Null.
+	NonFiniteNull
+
+	// NonFiniteError makes the conversion fail with an error.
+	NonFiniteError
+)
+
+// Py2StrPolicy is a policy to convert a str of Python 2, which is a byte
+// string. A unicode is always converted into data.String, and data.String is
+// always passed to Python 2 as a unicode.
+type Py2StrPolicy int
+
+const (
+	// Py2StrString converts a str into data.String regarding it as UTF-8. The
+	// bytes are kept as they are even if they aren't valid UTF-8.
+	Py2StrString Py2StrPolicy = iota
+
+	// Py2StrBlob converts a str into data.Blob.
+	Py2StrBlob
+
+	// Py2StrUTF8OrBlob converts a str into data.String when it's valid UTF-8.
+	// Otherwise, it's converted into data.Blob.
+	Py2StrUTF8OrBlob
+)
+
+var (
+	// defaultConvertOptions is used by all conversions which aren't given
+	// specific options. It must only be accessed on the main thread.
+	defaultConvertOptions = ConvertOptions{}
+
+	// defaultConvertOptionsVersion is incremented every time
+	// defaultConvertOptions is changed. It must be accessed atomically.
+	defaultConvertOptionsVersion uint64
+)
+
+// SetDefaultConvertOptions sets options used by all conversions which aren't
+// given specific options. Options derived from the default options, such as
+// ones of states of pystate, follow the new default options when they're used
+// next time.
+func SetDefaultConvertOptions(opts ConvertOptions) {
+	mainthread.ExecSync(func() {
+		defaultConvertOptions = opts
+		atomic.AddUint64(&defaultConvertOptionsVersion, 1)
+	})
+}
+
+// DefaultConvertOptionsVersion returns a number which is changed every time
+// SetDefaultConvertOptions is called. Users who derive their own options
+// from DefaultConvertOptions can compare the number to find out that the
+// derived options have to be built again. This function doesn't acquire the
+// GIL.
+func DefaultConvertOptionsVersion() uint64 {
+	return atomic.LoadUint64(&defaultConvertOptionsVersion)
+}
+
+// getConvertOptions returns opts if it isn't nil. Otherwise, it returns the
+// default options. The returned value must only be used on the main thread.
+func getConvertOptions(opts *ConvertOptions) *ConvertOptions {
+	if opts == nil {
+		return &defaultConvertOptions
+	}
+	return opts
+}
+
+// DefaultConvertOptions returns options currently used by all conversions
+// which aren't given specific options.
+func DefaultConvertOptions() ConvertOptions {
+	ch := make(chan ConvertOptions)
+	mainthread.Exec(func() {
+		ch <- defaultConvertOptions
+	})
+	return <-ch
+}
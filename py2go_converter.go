@@ -7,25 +7,212 @@ package py
 int IsPyTypeUnicode(PyObject *o) {
   return PyUnicode_CheckExact(o);
 }
+
+int IsPyTypeSet(PyObject *o) {
+  return PyAnySet_CheckExact(o);
+}
+
+const char* GetPyTypeName(PyObject *o) {
+  return Py_TYPE(o)->tp_name;
+}
+
+int IsPyTypeStringLike(PyObject *o) {
+  return PyUnicode_Check(o) || PyBytes_Check(o) || PyByteArray_Check(o);
+}
+
+int IsPyTypeListSubtype(PyObject *o) {
+  return PyList_Check(o);
+}
+
+int IsPyTypeDictSubtype(PyObject *o) {
+  return PyDict_Check(o);
+}
+
+int IsPyTypeTupleSubtype(PyObject *o) {
+  return PyTuple_Check(o);
+}
+
+PyObject* GetPyMappingItems(PyObject *o) {
+  return PyMapping_Items(o);
+}
+
+int GetPyDateYear(PyObject* o) {
+  return PyDateTime_GET_YEAR(o);
+}
+
+int GetPyDateMonth(PyObject* o) {
+  return PyDateTime_GET_MONTH(o);
+}
+
+int GetPyDateDay(PyObject* o) {
+  return PyDateTime_GET_DAY(o);
+}
+
+int GetPyTimeHour(PyObject* o) {
+  return PyDateTime_TIME_GET_HOUR(o);
+}
+
+int GetPyTimeMinute(PyObject* o) {
+  return PyDateTime_TIME_GET_MINUTE(o);
+}
+
+int GetPyTimeSecond(PyObject* o) {
+  return PyDateTime_TIME_GET_SECOND(o);
+}
+
+int GetPyTimeMicrosecond(PyObject* o) {
+  return PyDateTime_TIME_GET_MICROSECOND(o);
+}
 */
 import "C"
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
 	"time"
 	"unsafe"
 
+	"gopkg.in/sensorbee/py.v0/mainthread"
 	"gopkg.in/sensorbee/sensorbee.v0/data"
 )
 
+var (
+	pyMappingABC  Object
+	pySequenceABC Object
+)
+
+func init() {
+	ch := make(chan error)
+	mainthread.Exec(func() {
+		abc, err := loadModule(collectionsABCModuleName)
+		if err != nil {
+			ch <- err
+			return
+		}
+		defer abc.decRef()
+
+		for _, c := range []struct {
+			name string
+			obj  *Object
+		}{
+			{"Mapping", &pyMappingABC},
+			{"Sequence", &pySequenceABC},
+		} {
+			cName := C.CString(c.name)
+			p := C.PyObject_GetAttrString(abc.p, cName)
+			C.free(unsafe.Pointer(cName))
+			if p == nil {
+				C.PyErr_Clear()
+				ch <- fmt.Errorf("cannot load %v.%v", collectionsABCModuleName, c.name)
+				return
+			}
+			c.obj.p = p
+		}
+		ch <- nil
+	})
+
+	if err := <-ch; err != nil {
+		panic(err)
+	}
+}
+
+// getPyTypeName returns the name of the type of o.
+func getPyTypeName(o *C.PyObject) string {
+	return C.GoString(C.GetPyTypeName(o))
+}
+
 func isPyTypeUnicode(o *C.PyObject) int {
 	return int(C.IsPyTypeUnicode(o))
 }
 
-func fromPyArray(ls *C.PyObject) (data.Array, error) {
+// isPyTypeSet checks the object is `set` or `frozenset` type or not.
+func isPyTypeSet(o *C.PyObject) bool {
+	return C.IsPyTypeSet(o) > 0
+}
+
+// fromPyLong converts a Python int (or a long in Python 2) into data.Int. When
+// the value doesn't fit in data.Int, it's converted according to
+// opts.IntOverflow.
+func fromPyLong(o *C.PyObject, opts *ConvertOptions) (data.Value, error) {
+	var overflow C.int
+	i := C.PyLong_AsLongLongAndOverflow(o, &overflow)
+	if overflow == 0 {
+		if i == -1 && C.PyErr_Occurred() != nil {
+			return data.Null{}, getPyErr()
+		}
+		return data.Int(i), nil
+	}
+
+	switch opts.IntOverflow {
+	case IntOverflowFloat:
+		f := C.PyLong_AsDouble(o)
+		if f == -1 && C.PyErr_Occurred() != nil {
+			return data.Null{}, getPyErr()
+		}
+		return data.Float(f), nil
+
+	case IntOverflowString:
+		return fromPyStr(o, opts)
+
+	default:
+		s, err := fromPyStr(o, opts)
+		if err != nil {
+			return data.Null{}, err
+		}
+		return data.Null{}, fmt.Errorf("python int overflows data.Int: %v", s)
+	}
+}
+
+// fromFloat64 converts a float into data.Float. NaN and infinities are
+// converted according to opts.NonFinite.
+func fromFloat64(f float64, opts *ConvertOptions) (data.Value, error) {
+	if !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return data.Float(f), nil
+	}
+	switch opts.NonFinite {
+	case NonFiniteNull:
+		return data.Null{}, nil
+	case NonFiniteError:
+		return data.Null{}, fmt.Errorf("python float is not finite: %v", f)
+	default:
+		return data.Float(f), nil
+	}
+}
+
+// fromPyStr converts the result of `str(o)` into data.String.
+func fromPyStr(o *C.PyObject, opts *ConvertOptions) (data.Value, error) {
+	s := C.PyObject_Str(o)
+	if s == nil {
+		return data.Null{}, getPyErr()
+	}
+	defer C.Py_DecRef(s)
+	return fromPyTypeObject(s, withPy2StrString(opts))
+}
+
+// withPy2StrString returns options converting a str of Python 2 into
+// data.String. It's used when a result must be a string.
+func withPy2StrString(opts *ConvertOptions) *ConvertOptions {
+	if opts.Py2Str == Py2StrString {
+		return opts
+	}
+	o := *opts
+	o.Py2Str = Py2StrString
+	return &o
+}
+
+func fromPyArray(ls *C.PyObject, opts *ConvertOptions) (data.Array, error) {
+	if err := enterPyObject(ls, opts); err != nil {
+		return nil, err
+	}
+	defer leavePyObject(ls)
+
 	size := int(C.PyList_Size(ls))
 	array := make(data.Array, size)
 	for i := 0; i < size; i++ {
 		o := C.PyList_GetItem(ls, C.Py_ssize_t(i))
-		v, err := fromPyTypeObject(o)
+		v, err := fromPyTypeObject(o, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -34,20 +221,26 @@ func fromPyArray(ls *C.PyObject) (data.Array, error) {
 	return array, nil
 }
 
-func fromPyMap(o *C.PyObject) (data.Map, error) {
+func fromPyMap(o *C.PyObject, opts *ConvertOptions) (data.Map, error) {
+	if err := enterPyObject(o, opts); err != nil {
+		return nil, err
+	}
+	defer leavePyObject(o)
+
 	m := data.Map{}
 
 	var key, value *C.PyObject
 	pos := C.Py_ssize_t(C.int(0))
 
 	for C.int(C.PyDict_Next(o, &pos, &key, &value)) > 0 {
-		// data.Map's key is only allowed string or unicode
-		if isPyTypeString(key) == 0 && isPyTypeUnicode(key) == 0 {
+		key, ok, err := fromPyMapKey(key, opts)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
 			continue
 		}
-		k, _ := fromPyTypeObject(key)
-		key, _ := data.ToString(k)
-		v, err := fromPyTypeObject(value)
+		v, err := fromPyTypeObject(value, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -57,12 +250,202 @@ func fromPyMap(o *C.PyObject) (data.Map, error) {
 	return m, nil
 }
 
-func fromPyTuple(o *C.PyObject) (data.Array, error) {
+// fromPyMapKey converts a key of a dict into a key of data.Map. data.Map's key
+// is only allowed string or unicode, so other keys are converted according to
+// opts.MapKey. It returns false when the key should be skipped.
+func fromPyMapKey(key *C.PyObject, opts *ConvertOptions) (string, bool, error) {
+	if isPyTypeString(key) != 0 || isPyTypeUnicode(key) != 0 {
+		k, _ := fromPyTypeObject(key, withPy2StrString(opts))
+		s, _ := data.ToString(k)
+		return s, true, nil
+	}
+
+	switch opts.MapKey {
+	case MapKeyString:
+		k, err := fromPyStr(key, opts)
+		if err != nil {
+			return "", false, err
+		}
+		s, _ := data.ToString(k)
+		return s, true, nil
+
+	case MapKeyError:
+		return "", false, fmt.Errorf("unsupported key type of dict in sensorbee/py: %v",
+			getPyTypeName(key))
+
+	default:
+		return "", false, nil
+	}
+}
+
+// fromPyContainer converts an object which is a subtype of list, dict, or
+// tuple, or implements collections.abc.Mapping or collections.abc.Sequence.
+// str, bytes, and bytearray are never converted by this function. It returns
+// false when o isn't such an object.
+func fromPyContainer(o *C.PyObject, opts *ConvertOptions) (data.Value, bool, error) {
+	if C.IsPyTypeStringLike(o) > 0 {
+		return nil, false, nil
+	}
+
+	switch {
+	case C.IsPyTypeTupleSubtype(o) > 0:
+		if opts.NamedTupleAsMap {
+			if m, ok, err := fromPyNamedTuple(o, opts); ok {
+				return m, true, err
+			}
+		}
+		v, err := fromPyTuple(o, opts)
+		return v, true, err
+
+	case C.IsPyTypeListSubtype(o) > 0:
+		v, err := fromPyArray(o, opts)
+		return v, true, err
+
+	case C.IsPyTypeDictSubtype(o) > 0:
+		v, err := fromPyMapping(o, opts)
+		return v, true, err
+	}
+
+	if ok, err := isPyInstance(o, pyMappingABC.p); err != nil {
+		return nil, true, err
+	} else if ok {
+		v, err := fromPyMapping(o, opts)
+		return v, true, err
+	}
+
+	if ok, err := isPyInstance(o, pySequenceABC.p); err != nil {
+		return nil, true, err
+	} else if ok {
+		if err := enterPyObject(o, opts); err != nil {
+			return nil, true, err
+		}
+		defer leavePyObject(o)
+		ls := C.PySequence_List(o)
+		if ls == nil {
+			return nil, true, getPyErr()
+		}
+		defer C.Py_DecRef(ls)
+		v, err := fromPyArray(ls, opts)
+		return v, true, err
+	}
+	return nil, false, nil
+}
+
+func isPyInstance(o *C.PyObject, class *C.PyObject) (bool, error) {
+	switch C.PyObject_IsInstance(o, class) {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	default:
+		return false, getPyErr()
+	}
+}
+
+// fromPyMapping converts a mapping object into data.Map using its items
+// method, so the order of items defined by the object is respected.
+func fromPyMapping(o *C.PyObject, opts *ConvertOptions) (data.Map, error) {
+	if err := enterPyObject(o, opts); err != nil {
+		return nil, err
+	}
+	defer leavePyObject(o)
+
+	items := C.GetPyMappingItems(o)
+	if items == nil {
+		return nil, getPyErr()
+	}
+	defer C.Py_DecRef(items)
+	iter := C.PyObject_GetIter(items)
+	if iter == nil {
+		return nil, getPyErr()
+	}
+	defer C.Py_DecRef(iter)
+
+	m := data.Map{}
+	for {
+		item := C.PyIter_Next(iter)
+		if item == nil {
+			break
+		}
+		err := func() error {
+			defer C.Py_DecRef(item)
+			if C.IsPyTypeTupleSubtype(item) == 0 || C.PyTuple_Size(item) != 2 {
+				return errors.New("items of a mapping must be pairs of a key and a value")
+			}
+			key, ok, err := fromPyMapKey(C.PyTuple_GetItem(item, 0), opts)
+			if err != nil || !ok {
+				return err
+			}
+			v, err := fromPyTypeObject(C.PyTuple_GetItem(item, 1), opts)
+			if err != nil {
+				return err
+			}
+			m[key] = v
+			return nil
+		}()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if C.PyErr_Occurred() != nil {
+		return nil, getPyErr()
+	}
+	return m, nil
+}
+
+// fromPyNamedTuple converts a namedtuple into data.Map having its field names
+// as keys. It returns false when o doesn't have `_fields` attribute.
+func fromPyNamedTuple(o *C.PyObject, opts *ConvertOptions) (data.Map, bool, error) {
+	cFields := C.CString("_fields")
+	defer C.free(unsafe.Pointer(cFields))
+	fields := C.PyObject_GetAttrString(o, cFields)
+	if fields == nil {
+		C.PyErr_Clear()
+		return nil, false, nil
+	}
+	defer C.Py_DecRef(fields)
+	if C.IsPyTypeTupleSubtype(fields) == 0 {
+		return nil, false, nil
+	}
+
+	size := C.PyTuple_Size(fields)
+	if size != C.PyTuple_Size(o) {
+		return nil, false, nil
+	}
+	if err := enterPyObject(o, opts); err != nil {
+		return nil, true, err
+	}
+	defer leavePyObject(o)
+
+	m := data.Map{}
+	for i := C.Py_ssize_t(0); i < size; i++ {
+		key, ok, err := fromPyMapKey(C.PyTuple_GetItem(fields, i), opts)
+		if err != nil {
+			return nil, true, err
+		}
+		if !ok {
+			continue
+		}
+		v, err := fromPyTypeObject(C.PyTuple_GetItem(o, i), opts)
+		if err != nil {
+			return nil, true, err
+		}
+		m[key] = v
+	}
+	return m, true, nil
+}
+
+func fromPyTuple(o *C.PyObject, opts *ConvertOptions) (data.Array, error) {
+	if err := enterPyObject(o, opts); err != nil {
+		return nil, err
+	}
+	defer leavePyObject(o)
+
 	size := int(C.PyTuple_Size(o))
 	array := make(data.Array, size)
 	for i := 0; i < size; i++ {
 		o := C.PyTuple_GetItem(o, C.Py_ssize_t(i))
-		v, err := fromPyTypeObject(o)
+		v, err := fromPyTypeObject(o, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -71,10 +454,145 @@ func fromPyTuple(o *C.PyObject) (data.Array, error) {
 	return array, nil
 }
 
+// fromPySet converts a set or a frozenset into data.Array. The order of values
+// follows the iteration order of the set unless opts.SortSets is true.
+func fromPySet(o *C.PyObject, opts *ConvertOptions) (data.Array, error) {
+	if err := enterPyObject(o, opts); err != nil {
+		return nil, err
+	}
+	defer leavePyObject(o)
+
+	iter := C.PyObject_GetIter(o)
+	if iter == nil {
+		return nil, getPyErr()
+	}
+	defer C.Py_DecRef(iter)
+
+	array := make(data.Array, 0, int(C.PySet_Size(o)))
+	for {
+		item := C.PyIter_Next(iter)
+		if item == nil {
+			break
+		}
+		v, err := fromPyTypeObject(item, opts)
+		C.Py_DecRef(item)
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, v)
+	}
+	if C.PyErr_Occurred() != nil {
+		return nil, getPyErr()
+	}
+
+	if opts.SortSets {
+		sort.Stable(sortableValues(array))
+	}
+	return array, nil
+}
+
+// sortableValues sorts values converted from a Python set. See the godoc of
+// ConvertOptions.SortSets for the order.
+type sortableValues data.Array
+
+func (a sortableValues) Len() int      { return len(a) }
+func (a sortableValues) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a sortableValues) Less(i, j int) bool {
+	return compareValues(a[i], a[j]) < 0
+}
+
+// valueOrder returns the rank of the type of v used by compareValues.
+// Int and Float have the same rank so that they're compared as numbers.
+func valueOrder(v data.Value) int {
+	switch v.Type() {
+	case data.TypeNull:
+		return 0
+	case data.TypeBool:
+		return 1
+	case data.TypeInt, data.TypeFloat:
+		return 2
+	case data.TypeString:
+		return 3
+	case data.TypeBlob:
+		return 4
+	case data.TypeTimestamp:
+		return 5
+	case data.TypeArray:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// compareValues returns a negative number when l should be placed before r,
+// a positive number when r should be placed before l, and 0 otherwise.
+func compareValues(l, r data.Value) int {
+	lo, ro := valueOrder(l), valueOrder(r)
+	if lo != ro {
+		return lo - ro
+	}
+
+	switch lo {
+	case 1:
+		lb, _ := data.AsBool(l)
+		rb, _ := data.AsBool(r)
+		switch {
+		case lb == rb:
+			return 0
+		case rb:
+			return -1
+		default:
+			return 1
+		}
+	case 2:
+		if l.Type() == data.TypeInt && r.Type() == data.TypeInt {
+			li, _ := data.AsInt(l)
+			ri, _ := data.AsInt(r)
+			return compareOrdered(li < ri, li > ri)
+		}
+		lf, _ := data.ToFloat(l)
+		rf, _ := data.ToFloat(r)
+		return compareOrdered(lf < rf, lf > rf)
+	case 3:
+		ls, _ := data.AsString(l)
+		rs, _ := data.AsString(r)
+		return compareOrdered(ls < rs, ls > rs)
+	case 4:
+		lb, _ := data.AsBlob(l)
+		rb, _ := data.AsBlob(r)
+		return bytes.Compare(lb, rb)
+	case 5:
+		lt, _ := data.AsTimestamp(l)
+		rt, _ := data.AsTimestamp(r)
+		return compareOrdered(lt.Before(rt), lt.After(rt))
+	case 6:
+		la, _ := data.AsArray(l)
+		ra, _ := data.AsArray(r)
+		for i := 0; i < len(la) && i < len(ra); i++ {
+			if c := compareValues(la[i], ra[i]); c != 0 {
+				return c
+			}
+		}
+		return len(la) - len(ra)
+	}
+	return 0 // maps cannot be ordered
+}
+
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	default:
+		return 0
+	}
+}
+
 func fromTimestamp(o *C.PyObject) data.Timestamp {
 	// FIXME: this internal code should not use
 	d := (*C.PyDateTime_DateTime)(unsafe.Pointer(o))
-	t := time.Date(int(d.data[0])<<8|int(d.data[1]), time.Month(int(d.data[2])+1),
+	t := time.Date(int(d.data[0])<<8|int(d.data[1]), time.Month(int(d.data[2])),
 		int(d.data[3]), int(d.data[4]), int(d.data[5]), int(d.data[6]),
 		(int(d.data[7])<<16|int(d.data[8])<<8|int(d.data[9]))*1000,
 		time.UTC)
@@ -86,6 +604,40 @@ func fromTimestamp(o *C.PyObject) data.Timestamp {
 	return fromTimestampWithTimezone(o, t)
 }
 
+// fromPyDate converts a date according to opts.Date.
+func fromPyDate(o *C.PyObject, opts *ConvertOptions) data.Value {
+	year, month, day := int(C.GetPyDateYear(o)), int(C.GetPyDateMonth(o)),
+		int(C.GetPyDateDay(o))
+	if opts.Date == DateString {
+		return data.String(fmt.Sprintf("%04d-%02d-%02d", year, month, day))
+	}
+	return data.Timestamp(time.Date(year, time.Month(month), day, 0, 0, 0, 0,
+		time.UTC))
+}
+
+// fromPyTime converts a time according to opts.Time.
+func fromPyTime(o *C.PyObject, opts *ConvertOptions) (data.Value, error) {
+	if opts.Time == TimeMap {
+		return data.Map{
+			"hour":        data.Int(C.GetPyTimeHour(o)),
+			"minute":      data.Int(C.GetPyTimeMinute(o)),
+			"second":      data.Int(C.GetPyTimeSecond(o)),
+			"microsecond": data.Int(C.GetPyTimeMicrosecond(o)),
+		}, nil
+	}
+	return invokeNested(o, "isoformat", withPy2StrString(opts))
+}
+
+// fromPyTimeDelta converts a timedelta according to opts.TimeDelta.
+func fromPyTimeDelta(o *C.PyObject, opts *ConvertOptions) data.Value {
+	delta := (*C.PyDateTime_Delta)(unsafe.Pointer(o))
+	seconds := int64(delta.days)*24*60*60 + int64(delta.seconds)
+	if opts.TimeDelta == TimeDeltaMicroseconds {
+		return data.Int(seconds*1000000 + int64(delta.microseconds))
+	}
+	return data.Float(float64(seconds) + float64(delta.microseconds)/1e6)
+}
+
 // fromTimestampWithTimezone converts into data.Timestamp with UTC time zone
 // from datetime with tzinfo.  All of datetime passed to Go from Python API
 // must be unified into UTC time zone by this function.
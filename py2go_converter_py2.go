@@ -19,6 +19,10 @@ int IsPyTypeInt(PyObject *o) {
   return PyInt_CheckExact(o);
 }
 
+int IsPyTypeLong(PyObject *o) {
+  return PyLong_CheckExact(o);
+}
+
 int IsPyTypeFloat(PyObject *o) {
   return PyFloat_CheckExact(o);
 }
@@ -62,12 +66,17 @@ void DecRefTypeObject(PyTypeObject *t) {
 import "C"
 import (
 	"fmt"
+	"unicode/utf8"
 	"unsafe"
 
 	"gopkg.in/sensorbee/sensorbee.v0/data"
 )
 
-func fromPyTypeObject(o *C.PyObject) (data.Value, error) {
+// collectionsABCModuleName is the name of the module having abstract base
+// classes of containers such as Mapping and Sequence.
+const collectionsABCModuleName = "collections"
+
+func fromPyTypeObject(o *C.PyObject, opts *ConvertOptions) (data.Value, error) {
 	switch {
 	case C.IsPyTypeTrue(o) > 0:
 		return data.Bool(true), nil
@@ -78,8 +87,11 @@ func fromPyTypeObject(o *C.PyObject) (data.Value, error) {
 	case C.IsPyTypeInt(o) > 0:
 		return data.Int(C.PyInt_AsLong(o)), nil
 
+	case C.IsPyTypeLong(o) > 0:
+		return fromPyLong(o, opts)
+
 	case C.IsPyTypeFloat(o) > 0:
-		return data.Float(C.PyFloat_AsDouble(o)), nil
+		return fromFloat64(float64(C.PyFloat_AsDouble(o)), opts)
 
 	case C.IsPyTypeByteArray(o) > 0:
 		bytePtr := C.PyByteArray_FromObject(o)
@@ -88,9 +100,7 @@ func fromPyTypeObject(o *C.PyObject) (data.Value, error) {
 		return data.Blob(C.GoBytes(unsafe.Pointer(charPtr), C.int(l))), nil
 
 	case C.IsPyTypeString(o) > 0:
-		size := C.int(C.PyString_Size(o))
-		charPtr := C.PyString_AsString(o)
-		return data.String(string(C.GoBytes(unsafe.Pointer(charPtr), size))), nil
+		return fromPyString(o, opts), nil
 
 	case isPyTypeUnicode(o) > 0:
 		// Use unicode string as UTF-8 in py because
@@ -105,25 +115,61 @@ func fromPyTypeObject(o *C.PyObject) (data.Value, error) {
 		str := Object{p: strObj}
 		defer str.decRef()
 
-		return fromPyTypeObject(str.p)
+		return data.String(pyStringBytes(str.p)), nil
 
 	case isPyTypeDateTime(o):
 		return fromTimestamp(o), nil
 
+	case isPyTypeDate(o):
+		return fromPyDate(o, opts), nil
+
+	case isPyTypeTime(o):
+		return fromPyTime(o, opts)
+
+	case isPyTypeTimeDelta(o):
+		return fromPyTimeDelta(o, opts), nil
+
 	case C.IsPyTypeList(o) > 0:
-		return fromPyArray(o)
+		return fromPyArray(o, opts)
 
 	case C.IsPyTypeDict(o) > 0:
-		return fromPyMap(o)
+		return fromPyMap(o, opts)
 
 	case C.IsPyTypeTuple(o) > 0:
-		return fromPyTuple(o)
+		return fromPyTuple(o, opts)
+
+	case isPyTypeSet(o):
+		return fromPySet(o, opts)
 
 	case C.IsPyTypeNone(o) > 0:
 		return data.Null{}, nil
 
 	}
 
+	if v, ok, err := fromPy2GoConverter(o, opts); ok {
+		return v, err
+	}
+
+	if v, ok, err := fromPyDecimal(o, opts); ok {
+		return v, err
+	}
+
+	if v, ok, err := fromNumPyObject(o, opts); ok {
+		return v, err
+	}
+
+	if v, ok, err := fromPyContainer(o, opts); ok {
+		return v, err
+	}
+
+	if v, ok, err := fromPyObjectFallback(o, opts); ok {
+		return v, err
+	}
+
+	if opts.HandleUnsupported {
+		return newPyHandle(o, opts), nil
+	}
+
 	t := C.GetTypeObject(o)
 	if t == nil {
 		return data.Null{}, fmt.Errorf("unsupported type in sensorbee/py (cannot detect python object type)")
@@ -133,6 +179,26 @@ func fromPyTypeObject(o *C.PyObject) (data.Value, error) {
 	return data.Null{}, fmt.Errorf("unsupported type in sensorbee/py: %v", tn)
 }
 
+// fromPyString converts a str according to opts.Py2Str.
+func fromPyString(o *C.PyObject, opts *ConvertOptions) data.Value {
+	b := pyStringBytes(o)
+	switch opts.Py2Str {
+	case Py2StrBlob:
+		return data.Blob(b)
+	case Py2StrUTF8OrBlob:
+		if !utf8.Valid(b) {
+			return data.Blob(b)
+		}
+	}
+	return data.String(b)
+}
+
+func pyStringBytes(o *C.PyObject) []byte {
+	size := C.int(C.PyString_Size(o))
+	charPtr := C.PyString_AsString(o)
+	return C.GoBytes(unsafe.Pointer(charPtr), size)
+}
+
 func isPyTypeString(o *C.PyObject) int {
 	return int(C.IsPyTypeString(o))
 }
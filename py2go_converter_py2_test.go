@@ -0,0 +1,66 @@
+// +build !py3.4
+// +build !py3.5
+// +build !py3.6
+
+package py
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/py.v0/mainthread"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestConvertPy2Str2Go(t *testing.T) {
+	Convey("Given an initialized python py2go test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_py2go")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		cases := []struct {
+			title    string
+			policy   Py2StrPolicy
+			expected data.Value
+		}{
+			{"Py2StrString", Py2StrString, data.Map{
+				"utf8": data.String("\xe3\x81\x82"), "binary": data.String("\xff"),
+				"unicode": data.String("\xe3\x81\x82"),
+			}},
+			{"Py2StrBlob", Py2StrBlob, data.Map{
+				"utf8": data.Blob("\xe3\x81\x82"), "binary": data.Blob("\xff"),
+				"unicode": data.String("\xe3\x81\x82"),
+			}},
+			{"Py2StrUTF8OrBlob", Py2StrUTF8OrBlob, data.Map{
+				"utf8": data.String("\xe3\x81\x82"), "binary": data.Blob("\xff"),
+				"unicode": data.String("\xe3\x81\x82"),
+			}},
+		}
+
+		for _, c := range cases {
+			c := c
+			Convey("When converting str with "+c.title, func() {
+				SetDefaultConvertOptions(ConvertOptions{Py2Str: c.policy})
+				Reset(func() {
+					SetDefaultConvertOptions(ConvertOptions{})
+				})
+
+				Convey("Then str should be converted according to the policy", func() {
+					actual, err := mdl.Call("return_py2_strs")
+					So(err, ShouldBeNil)
+					So(actual, ShouldResemble, c.expected)
+				})
+
+				Convey("Then a time should be converted into a string", func() {
+					actual, err := mdl.Call("return_time")
+					So(err, ShouldBeNil)
+					So(actual, ShouldResemble, data.String("14:27:00.500000"))
+				})
+			})
+		}
+	})
+}
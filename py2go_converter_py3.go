@@ -65,7 +65,11 @@ import (
 	"gopkg.in/sensorbee/sensorbee.v0/data"
 )
 
-func fromPyTypeObject(o *C.PyObject) (data.Value, error) {
+// collectionsABCModuleName is the name of the module having abstract base
+// classes of containers such as Mapping and Sequence.
+const collectionsABCModuleName = "collections.abc"
+
+func fromPyTypeObject(o *C.PyObject, opts *ConvertOptions) (data.Value, error) {
 	switch {
 	case C.IsPyTypeTrue(o) > 0:
 		return data.Bool(true), nil
@@ -74,10 +78,10 @@ func fromPyTypeObject(o *C.PyObject) (data.Value, error) {
 		return data.Bool(false), nil
 
 	case C.IsPyTypeLong(o) > 0:
-		return data.Int(C.PyLong_AsLong(o)), nil
+		return fromPyLong(o, opts)
 
 	case C.IsPyTypeFloat(o) > 0:
-		return data.Float(C.PyFloat_AsDouble(o)), nil
+		return fromFloat64(float64(C.PyFloat_AsDouble(o)), opts)
 
 	case C.IsPyTypeByteArray(o) > 0:
 		bytePtr := C.PyByteArray_FromObject(o)
@@ -103,20 +107,56 @@ func fromPyTypeObject(o *C.PyObject) (data.Value, error) {
 	case isPyTypeDateTime(o):
 		return fromTimestamp(o), nil
 
+	case isPyTypeDate(o):
+		return fromPyDate(o, opts), nil
+
+	case isPyTypeTime(o):
+		return fromPyTime(o, opts)
+
+	case isPyTypeTimeDelta(o):
+		return fromPyTimeDelta(o, opts), nil
+
 	case C.IsPyTypeList(o) > 0:
-		return fromPyArray(o)
+		return fromPyArray(o, opts)
 
 	case C.IsPyTypeDict(o) > 0:
-		return fromPyMap(o)
+		return fromPyMap(o, opts)
 
 	case C.IsPyTypeTuple(o) > 0:
-		return fromPyTuple(o)
+		return fromPyTuple(o, opts)
+
+	case isPyTypeSet(o):
+		return fromPySet(o, opts)
 
 	case C.IsPyTypeNone(o) > 0:
 		return data.Null{}, nil
 
 	}
 
+	if v, ok, err := fromPy2GoConverter(o, opts); ok {
+		return v, err
+	}
+
+	if v, ok, err := fromPyDecimal(o, opts); ok {
+		return v, err
+	}
+
+	if v, ok, err := fromNumPyObject(o, opts); ok {
+		return v, err
+	}
+
+	if v, ok, err := fromPyContainer(o, opts); ok {
+		return v, err
+	}
+
+	if v, ok, err := fromPyObjectFallback(o, opts); ok {
+		return v, err
+	}
+
+	if opts.HandleUnsupported {
+		return newPyHandle(o, opts), nil
+	}
+
 	t := C.GetTypeObject(o)
 	if t == nil {
 		return data.Null{}, fmt.Errorf("unsupported type in sensorbee/py (cannot detect python object type)")
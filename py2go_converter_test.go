@@ -5,6 +5,7 @@ import (
 	. "github.com/smartystreets/goconvey/convey"
 	"gopkg.in/sensorbee/py.v0/mainthread"
 	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"math"
 	"testing"
 	"time"
 )
@@ -24,18 +25,37 @@ func TestConvertPyObject2Go(t *testing.T) {
 			{"true", data.Bool(true)},
 			{"false", data.Bool(false)},
 			{"int", data.Int(123)},
+			{"long", data.Int(4294967296)},
+			{"min_int", data.Int(-9223372036854775808)},
 			{"float", data.Float(1.0)},
 			{"string", data.String("ABC")},
 			{"unicode", data.String("hello")},
 			{"bytearray", data.Blob([]byte("abcdefg"))},
 			{"map", data.Map{"key1": data.Int(123), "key2": data.String("str")}},
+			{"map_with_non_string_keys", data.Map{"key": data.String("b")}},
 			{"nested_map", data.Map{"key1": data.Map{"key2": data.Int(123)}}},
 			{"array", data.Array{data.Int(1), data.Int(2), data.Map{"key": data.Int(3)}}},
 			{"none", data.Null{}},
-			{"timestamp", data.Timestamp(time.Date(2015, time.May, 1, 14, 27, 0, 500*int(time.Millisecond), time.UTC))},
-			{"timestamp_with_tz", data.Timestamp(time.Date(2015, time.May, 1, 5, 24, 0, 500*int(time.Millisecond), time.UTC))},
+			{"timestamp", data.Timestamp(time.Date(2015, time.April, 1, 14, 27, 0, 500*int(time.Millisecond), time.UTC))},
+			{"timestamp_with_tz", data.Timestamp(time.Date(2015, time.April, 1, 5, 24, 0, 500*int(time.Millisecond), time.UTC))},
+			{"date", data.Timestamp(time.Date(2015, time.April, 1, 0, 0, 0, 0, time.UTC))},
+			{"time", data.String("14:27:00.500000")},
+			{"timedelta", data.Float(86403.5)},
+			{"negative_timedelta", data.Float(-1.5)},
 			{"onetuple", data.Array{data.String("a"), data.Map{"key1": data.Int(1)}, data.Array{data.Int(1), data.Int(2)}}},
 			{"astuple", data.Array{data.String("a"), data.Map{"key1": data.Int(1)}, data.Array{data.Int(1), data.Int(2)}}},
+			{"set", data.Array{data.Int(1)}},
+			{"ordered_dict", data.Map{"key1": data.Int(1), "key2": data.Int(2)}},
+			{"default_dict", data.Map{"key": data.Array{data.Int(1)}}},
+			{"counter", data.Map{"a": data.Int(2), "b": data.Int(1)}},
+			{"list_subclass", data.Array{data.Int(1), data.Int(2)}},
+			{"custom_mapping", data.Map{"key": data.Array{data.Int(1), data.Int(2)}}},
+			{"namedtuple", data.Array{data.Int(1), data.Int(2)}},
+			{"fake_ndarray", data.Array{data.Float(1.0), data.Float(2.5)}},
+			{"fake_int_ndarray", data.Array{data.Int(1), data.Int(-2)}},
+			{"fake_matrix", data.Array{data.Array{data.Int(1), data.Int(2)}, data.Array{data.Int(3), data.Int(4)}}},
+			{"fake_numpy_scalar", data.Float(1.5)},
+			{"frozenset", data.Array{data.String("a")}},
 		}
 
 		for _, r := range returnTypes {
@@ -56,6 +76,507 @@ func TestConvertPyObject2Go(t *testing.T) {
 	})
 }
 
+func TestConvertPyDatetimeMonth2Go(t *testing.T) {
+	Convey("Given an initialized python py2go test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_py2go")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+
+		Convey("When call python function which returns a datetime of each month", func() {
+			Convey("Then the month should be kept", func() {
+				for m := time.January; m <= time.December; m++ {
+					day := 1
+					if m == time.December {
+						day = 31 // the last day of the year
+					}
+					ac, err := mdl.Call("return_month_timestamp", data.Int(m))
+					So(err, ShouldBeNil)
+					So(ac, ShouldEqual, data.Timestamp(time.Date(2015, m, day, 23, 59, 59, 0, time.UTC)))
+				}
+			})
+		})
+	})
+}
+
+func TestConvertPySet2Go(t *testing.T) {
+	Convey("Given an initialized python py2go test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_py2go")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When sorting sets is enabled", func() {
+			SetDefaultConvertOptions(ConvertOptions{SortSets: true})
+			Reset(func() {
+				SetDefaultConvertOptions(ConvertOptions{})
+			})
+
+			Convey("Then a set should be converted into a sorted array", func() {
+				actual, err := mdl.Call("return_mixed_set")
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, data.Array{
+					data.Null{},
+					data.True,
+					data.Float(1.5),
+					data.Int(3),
+					data.String("a"),
+					data.String("b"),
+					data.Array{data.Int(1), data.Int(2)},
+					data.Array{data.Int(2), data.Int(1)},
+				})
+			})
+		})
+
+		Convey("When sorting sets is disabled", func() {
+			Convey("Then a set should be converted into an array", func() {
+				actual, err := mdl.Call("return_mixed_set")
+				So(err, ShouldBeNil)
+				So(actual, ShouldHaveLength, 8)
+			})
+		})
+	})
+}
+
+func TestConvertPyBigInt2Go(t *testing.T) {
+	Convey("Given an initialized python py2go test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_py2go")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When calling a function returning an int overflowing data.Int", func() {
+			Convey("Then it should return an error by default", func() {
+				_, err := mdl.Call("return_big_int")
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "18446744073709551616")
+			})
+
+			Convey("Then it should return a float with IntOverflowFloat", func() {
+				SetDefaultConvertOptions(ConvertOptions{IntOverflow: IntOverflowFloat})
+				Reset(func() {
+					SetDefaultConvertOptions(ConvertOptions{})
+				})
+				actual, err := mdl.Call("return_big_int")
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, data.Float(18446744073709551616))
+			})
+
+			Convey("Then it should return a string with IntOverflowString", func() {
+				SetDefaultConvertOptions(ConvertOptions{IntOverflow: IntOverflowString})
+				Reset(func() {
+					SetDefaultConvertOptions(ConvertOptions{})
+				})
+				actual, err := mdl.Call("return_big_int")
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, data.String("18446744073709551616"))
+			})
+		})
+	})
+}
+
+func TestConvertPyTimes2GoWithOptions(t *testing.T) {
+	Convey("Given an initialized python py2go test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_py2go")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When setting alternative conversions of times", func() {
+			SetDefaultConvertOptions(ConvertOptions{
+				TimeDelta: TimeDeltaMicroseconds,
+				Date:      DateString,
+				Time:      TimeMap,
+			})
+			Reset(func() {
+				SetDefaultConvertOptions(ConvertOptions{})
+			})
+
+			Convey("Then a timedelta should be converted into microseconds", func() {
+				actual, err := mdl.Call("return_timedelta")
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, data.Int(86403500000))
+
+				actual, err = mdl.Call("return_negative_timedelta")
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, data.Int(-1500000))
+			})
+
+			Convey("Then a date should be converted into a string", func() {
+				actual, err := mdl.Call("return_date")
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, data.String("2015-04-01"))
+			})
+
+			Convey("Then a time should be converted into a map", func() {
+				actual, err := mdl.Call("return_time")
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, data.Map{
+					"hour":        data.Int(14),
+					"minute":      data.Int(27),
+					"second":      data.Int(0),
+					"microsecond": data.Int(500000),
+				})
+			})
+		})
+	})
+}
+
+func TestConvertPyMapKeys2Go(t *testing.T) {
+	Convey("Given an initialized python py2go test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_py2go")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When converting non-string keys with MapKeyString", func() {
+			SetDefaultConvertOptions(ConvertOptions{MapKey: MapKeyString})
+			Reset(func() {
+				SetDefaultConvertOptions(ConvertOptions{})
+			})
+
+			Convey("Then keys should be converted with str", func() {
+				actual, err := mdl.Call("return_map_with_non_string_keys")
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, data.Map{
+					"1":   data.String("a"),
+					"key": data.String("b"),
+				})
+			})
+		})
+
+		Convey("When converting non-string keys with MapKeyError", func() {
+			SetDefaultConvertOptions(ConvertOptions{MapKey: MapKeyError})
+			Reset(func() {
+				SetDefaultConvertOptions(ConvertOptions{})
+			})
+
+			Convey("Then it should fail with the type of the key", func() {
+				_, err := mdl.Call("return_map_with_non_string_keys")
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "int")
+			})
+		})
+	})
+}
+
+func TestConvertPyNamedTuple2Go(t *testing.T) {
+	Convey("Given an initialized python py2go test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_py2go")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When converting a namedtuple with NamedTupleAsMap", func() {
+			SetDefaultConvertOptions(ConvertOptions{NamedTupleAsMap: true})
+			Reset(func() {
+				SetDefaultConvertOptions(ConvertOptions{})
+			})
+
+			Convey("Then it should be converted into a map keyed by field names", func() {
+				actual, err := mdl.Call("return_namedtuple")
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, data.Map{"x": data.Int(1), "y": data.Int(2)})
+			})
+
+			Convey("Then a plain tuple should still be converted into an array", func() {
+				actual, err := mdl.Call("return_onetuple")
+				So(err, ShouldBeNil)
+				So(actual, ShouldHaveSameTypeAs, data.Array{})
+			})
+		})
+	})
+}
+
+func TestConvertNumPyObject2Go(t *testing.T) {
+	if _, err := LoadModule("numpy"); err != nil {
+		t.Skip("numpy is not installed")
+	}
+
+	Convey("Given an initialized python py2go test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_py2go")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When calling a function returning a strided ndarray", func() {
+			actual, err := mdl.Call("return_numpy_matrix")
+
+			Convey("Then it should be converted into a nested array", func() {
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, data.Array{
+					data.Array{data.Float(2), data.Float(1.5)},
+					data.Array{data.Float(4), data.Float(3)},
+				})
+			})
+		})
+
+		Convey("When calling a function returning a NumPy scalar", func() {
+			actual, err := mdl.Call("return_numpy_scalar")
+
+			Convey("Then it should be converted into a scalar", func() {
+				So(err, ShouldBeNil)
+				So(actual, ShouldEqual, data.Int(3))
+			})
+		})
+	})
+}
+
+func TestConvertPyObjectFallback2Go(t *testing.T) {
+	Convey("Given an initialized python py2go test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_py2go")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When converting objects with ObjectFallback", func() {
+			SetDefaultConvertOptions(ConvertOptions{ObjectFallback: true})
+			Reset(func() {
+				SetDefaultConvertOptions(ConvertOptions{})
+			})
+
+			Convey("Then an object should be converted with __sensorbee__", func() {
+				actual, err := mdl.Call("return_sensorbee_object")
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, data.Map{
+					"kind":   data.String("custom"),
+					"values": data.Array{data.Int(1), data.Int(2)},
+				})
+			})
+
+			Convey("Then an object should be converted with vars and _asdict recursively", func() {
+				actual, err := mdl.Call("return_plain_object")
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, data.Map{
+					"name":  data.String("plain"),
+					"child": data.Map{"a": data.Int(1)},
+				})
+			})
+
+			if _, err := LoadModule("dataclasses"); err == nil {
+				Convey("Then a dataclass should be converted with dataclasses.asdict", func() {
+					actual, err := mdl.Call("return_dataclass")
+					So(err, ShouldBeNil)
+					So(actual, ShouldResemble, data.Array{
+						data.Map{"x": data.Int(1), "y": data.Int(2)},
+					})
+				})
+			}
+
+			Convey("Then an object without __dict__ should not be converted", func() {
+				_, err := mdl.Call("return_slots_object")
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "SlotsObject")
+			})
+		})
+
+		Convey("When converting an object without ObjectFallback", func() {
+			_, err := mdl.Call("return_plain_object")
+
+			Convey("Then the conversion should fail", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "PlainObject")
+			})
+		})
+	})
+}
+
+func TestConvertDeepPyObject2Go(t *testing.T) {
+	Convey("Given an initialized python py2go test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_py2go")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When converting containers having circular references", func() {
+			Convey("Then a self referencing list should fail to be converted", func() {
+				_, err := mdl.Call("return_self_referencing_list")
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "circular reference")
+			})
+
+			Convey("Then a self referencing dict should fail to be converted", func() {
+				_, err := mdl.Call("return_self_referencing_dict")
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "circular reference")
+			})
+
+			Convey("Then a self referencing object should fail to be converted", func() {
+				SetDefaultConvertOptions(ConvertOptions{ObjectFallback: true})
+				Reset(func() {
+					SetDefaultConvertOptions(ConvertOptions{})
+				})
+				_, err := mdl.Call("return_self_referencing_object")
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "circular reference")
+			})
+
+			Convey("Then a list referenced twice should be converted", func() {
+				actual, err := mdl.Call("return_shared_list")
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, data.Array{
+					data.Array{data.Int(1)}, data.Array{data.Int(1)},
+				})
+			})
+		})
+
+		Convey("When converting nested lists with MaxDepth", func() {
+			SetDefaultConvertOptions(ConvertOptions{MaxDepth: 3})
+			Reset(func() {
+				SetDefaultConvertOptions(ConvertOptions{})
+			})
+
+			Convey("Then lists nested up to the depth should be converted", func() {
+				actual, err := mdl.Call("return_nested_list", data.Int(3))
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, data.Array{data.Array{data.Array{}}})
+			})
+
+			Convey("Then deeper lists should fail to be converted", func() {
+				_, err := mdl.Call("return_nested_list", data.Int(4))
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "maximum depth")
+			})
+		})
+
+		Convey("When converting deeply nested lists with the default MaxDepth", func() {
+			_, err := mdl.Call("return_nested_list", data.Int(DefaultMaxDepth+1))
+
+			Convey("Then the conversion should fail", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "maximum depth")
+			})
+		})
+	})
+}
+
+func TestConvertPyDecimal2Go(t *testing.T) {
+	Convey("Given an initialized python py2go test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_py2go")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When converting Decimal and Fraction with the default options", func() {
+			actual, err := mdl.Call("return_decimals")
+
+			Convey("Then they should be converted into floats", func() {
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, data.Array{data.Float(1.5), data.Float(0.75)})
+			})
+		})
+
+		Convey("When converting Decimal and Fraction with DecimalString", func() {
+			SetDefaultConvertOptions(ConvertOptions{Decimal: DecimalString})
+			Reset(func() {
+				SetDefaultConvertOptions(ConvertOptions{})
+			})
+			actual, err := mdl.Call("return_decimals")
+
+			Convey("Then they should be converted into exact strings", func() {
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, data.Array{data.String("1.50"), data.String("3/4")})
+			})
+		})
+	})
+}
+
+func TestConvertPyNonFiniteFloat2Go(t *testing.T) {
+	Convey("Given an initialized python py2go test module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_py2go")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When converting non-finite floats with the default options", func() {
+			actual, err := mdl.Call("return_non_finite_floats")
+
+			Convey("Then they should be passed through", func() {
+				So(err, ShouldBeNil)
+				a, err := data.AsArray(actual)
+				So(err, ShouldBeNil)
+				So(len(a), ShouldEqual, 5)
+				f, _ := data.AsFloat(a[0])
+				So(math.IsNaN(f), ShouldBeTrue)
+				So(a[1], ShouldEqual, data.Float(math.Inf(1)))
+				So(a[2], ShouldEqual, data.Float(math.Inf(-1)))
+				f, _ = data.AsFloat(a[3])
+				So(math.IsNaN(f), ShouldBeTrue)
+				So(a[4], ShouldEqual, data.Float(1))
+			})
+		})
+
+		Convey("When converting non-finite floats with NonFiniteNull", func() {
+			SetDefaultConvertOptions(ConvertOptions{NonFinite: NonFiniteNull})
+			Reset(func() {
+				SetDefaultConvertOptions(ConvertOptions{})
+			})
+			actual, err := mdl.Call("return_non_finite_floats")
+
+			Convey("Then they should be converted into null", func() {
+				So(err, ShouldBeNil)
+				So(actual, ShouldResemble, data.Array{
+					data.Null{}, data.Null{}, data.Null{}, data.Null{}, data.Float(1),
+				})
+			})
+		})
+
+		Convey("When converting non-finite floats with NonFiniteError", func() {
+			SetDefaultConvertOptions(ConvertOptions{NonFinite: NonFiniteError})
+			Reset(func() {
+				SetDefaultConvertOptions(ConvertOptions{})
+			})
+			_, err := mdl.Call("return_non_finite_floats")
+
+			Convey("Then the conversion should fail", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "not finite")
+			})
+		})
+	})
+}
+
 func TestUnsupportedPyObject2Go(t *testing.T) {
 	Convey("Given an initialized python py2go test module", t, func() {
 		mainthread.AppendSysPath("")
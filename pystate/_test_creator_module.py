@@ -1,3 +1,6 @@
+import decimal
+
+import sensorbee
 import six
 
 
@@ -11,6 +14,30 @@ class TestClass(object):
     def write(self, value):
         return 'called! arg is "{}"'.format(str(value))
 
+    def histogram(self):
+        return {1: 'a', 'key': 'b'}
+
+    def predict(self, x, scale=1, offset=0):
+        return x * scale + offset
+
+    def make_object(self):
+        return object()
+
+    def type_name(self, value):
+        return type(value).__name__
+
+    def price(self, value=None):
+        if value is None:
+            return decimal.Decimal('10.50')
+        return value + 1
+
+    def infinity(self):
+        return float('inf')
+
+    def state_attr(self, name, attr):
+        sensorbee.info('reading %s of %s', attr, name)
+        return getattr(sensorbee.get_state(name), attr)
+
 
 class TestClass2(object):
 
@@ -26,6 +53,28 @@ class TestClass2(object):
             str(self.v1), str(self.v2))
 
 
+class TestClassCopyAttr(object):
+
+    @staticmethod
+    def create(source, attr):
+        self = TestClassCopyAttr()
+        self.value = getattr(sensorbee.get_state(source), attr)
+        return self
+
+    @staticmethod
+    def load(filepath, source, attr):
+        self = TestClassCopyAttr.create(source, attr)
+        self.loaded = True
+        return self
+
+    def save(self, filepath, *args, **kwargs):
+        with open(filepath, 'w') as f:
+            f.write('saved')
+
+    def confirm(self):
+        return self.value
+
+
 class TestClass3(object):
 
     @staticmethod
@@ -75,3 +124,46 @@ class TestClassTerminateError(object):
 
     def terminate(self):
         return 1 / 0  # cause ZeroDivisionError on purpose
+
+
+class ErrorPolicyTestError(ValueError):
+    pass
+
+
+class TestClassErrorPolicy(object):
+    created = 0
+
+    @staticmethod
+    def create(**params):
+        TestClassErrorPolicy.created += 1
+        self = TestClassErrorPolicy()
+        self.generation = TestClassErrorPolicy.created
+        self.calls = 0
+        self.params = params
+        return self
+
+    @staticmethod
+    def load(filepath, **params):
+        self = TestClassErrorPolicy.create(**params)
+        self.loaded = True
+        return self
+
+    def save(self, filepath, *args, **kwargs):
+        with open(filepath, 'w') as f:
+            f.write('TestClassErrorPolicy')
+
+    def fail(self, kind, times):
+        """fail raises an exception of kind until it's called times times."""
+        self.calls += 1
+        if self.calls <= times:
+            if kind == 'io':
+                raise IOError('transient error')
+            if kind == 'value':
+                raise ValueError('invalid value')
+            if kind == 'custom':
+                raise ErrorPolicyTestError('custom error')
+            raise RuntimeError('runtime error')
+        return self.calls
+
+    def write(self, value):
+        raise ValueError('invalid tuple')
@@ -1,6 +1,7 @@
 package pystate
 
 import (
+	"archive/zip"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -12,6 +13,10 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // ErrAlreadyTerminated is occurred when called some python method after the
@@ -22,6 +27,7 @@ var ErrAlreadyTerminated = errors.New("pystate is already terminated")
 // statement.
 type BaseParams struct {
 	// ModulePath is a path at where the target Python module is located.
+	// It can also be a path of a zip archive or a wheel having the module.
 	// This parameter can be set as "module_path" in a WITH clause. This is
 	// a required parameter.
 	ModulePath string `codec:"module_path"`
@@ -30,6 +36,12 @@ type BaseParams struct {
 	// can be set as "module_name" in a WITH clause. This is a required parameter.
 	ModuleName string `codec:"module_name"`
 
+	// ModuleSource is Python source code of the module. When it's specified,
+	// the module named ModuleName is created from the source instead of being
+	// loaded from ModulePath. This parameter can be set as "module_source" in
+	// a WITH clause.
+	ModuleSource string `codec:"module_source"`
+
 	// ClassName is a name of a class in the Python module to be loaded. This
 	// parameter can be set as "class_name" in a WITH clause. This is a required
 	// parameter.
@@ -39,6 +51,77 @@ type BaseParams struct {
 	// is mostly done by 'uds' Sink. When this parameter is specified, a UDS
 	// will be writable. Otherwise, it doesn't support Write.
 	WriteMethodName string `codec:"write_method"`
+
+	// MapKeyPolicy decides how to handle a key of a Python dict which is not
+	// a string. It must be one of "skip", "str", or "error". See
+	// py.MapKeyPolicy for details. This parameter can be set as
+	// "map_key_policy" in a WITH clause. When it's omitted, the default
+	// option of py package is used.
+	MapKeyPolicy string `codec:"map_key_policy"`
+
+	// DecimalPolicy decides how to convert a Python decimal.Decimal or
+	// fractions.Fraction. It must be "float" or "string". See
+	// py.DecimalPolicy for details. This parameter can be set as
+	// "decimal_policy" in a WITH clause. When it's omitted, the default
+	// option of py package is used.
+	DecimalPolicy string `codec:"decimal_policy"`
+
+	// StringAsDecimal passes a string representing a decimal number to Python
+	// as decimal.Decimal. This parameter can be set as "string_as_decimal" in
+	// a WITH clause. When it's omitted, the default option of py package is
+	// used.
+	StringAsDecimal *bool `codec:"string_as_decimal"`
+
+	// Py2StrPolicy decides how to convert a str of Python 2. It must be one
+	// of "string", "blob", or "utf8_or_blob". See py.Py2StrPolicy for
+	// details. It doesn't affect Python 3. This parameter can be set as
+	// "py2_str_policy" in a WITH clause. When it's omitted, the default
+	// option of py package is used.
+	Py2StrPolicy string `codec:"py2_str_policy"`
+
+	// HandleUnsupported converts a Python object which cannot be converted
+	// into a handle. See py.ConvertOptions.HandleUnsupported for details.
+	// This parameter can be set as "handle_unsupported" in a WITH clause.
+	// When it's omitted, the default option of py package is used.
+	HandleUnsupported *bool `codec:"handle_unsupported"`
+
+	// HandleTTL is the TTL of handles created by the state in seconds. This
+	// parameter can be set as "handle_ttl" in a WITH clause. When it's
+	// omitted, the default option of py package is used.
+	HandleTTL float64 `codec:"handle_ttl"`
+
+	// ReloadWatchInterval is the interval in seconds to check the
+	// modification time of the module file. When the file is modified, the
+	// state is reloaded as Reload does. This is mainly for development and
+	// cannot be used with ModuleSource. This parameter can be set as
+	// "reload_watch_interval" in a WITH clause. When it's omitted, the module
+	// file isn't watched.
+	ReloadWatchInterval float64 `codec:"reload_watch_interval"`
+
+	// ErrorPolicy maps names of exception classes to actions taken when
+	// the exceptions are raised by methods called from BQL functions or by
+	// Write. An action is one of "null", "propagate", "retry N", or
+	// "recreate". The action for "default" is applied to exceptions not
+	// matching any class. See README for details. This parameter can be set
+	// as "error_policy" in a WITH clause. When it's omitted, all exceptions
+	// are propagated.
+	ErrorPolicy map[string]string `codec:"error_policy"`
+
+	// StateName is the name of the state added to logs written by the
+	// instance as "pystate" field. It should be the same name as the one in
+	// the CREATE STATE statement because SensorBee doesn't pass the name to
+	// the state. This parameter can be set as "state_name" in a WITH clause
+	// or a SET clause of LOAD STATE. When it's omitted, the name is added
+	// once the state is looked up by its name or written by a sink. It isn't
+	// saved because a state can be loaded with another name.
+	StateName string `codec:"-"`
+
+	// CreateParams has parameters passed to 'create' static method when the
+	// state was created, encoded by data.MarshalMsgpack. They're saved with
+	// other parameters so that a loaded state can be re-created. This isn't a
+	// parameter of a WITH clause and it's set by NewBase. It's nil when the
+	// state was saved by an older version.
+	CreateParams []byte `codec:"create_params"`
 }
 
 // BaseLoadParams has parameters for Base given in SET clause of LOAD STATE
@@ -49,10 +132,37 @@ type BaseLoadParams struct {
 }
 
 var (
-	modulePath      = data.MustCompilePath("module_path")
-	moduleNamePath  = data.MustCompilePath("module_name")
-	classNamePath   = data.MustCompilePath("class_name")
-	writeMethodPath = data.MustCompilePath("write_method")
+	modulePath            = data.MustCompilePath("module_path")
+	moduleNamePath        = data.MustCompilePath("module_name")
+	moduleSourcePath      = data.MustCompilePath("module_source")
+	classNamePath         = data.MustCompilePath("class_name")
+	writeMethodPath       = data.MustCompilePath("write_method")
+	mapKeyPolicyPath      = data.MustCompilePath("map_key_policy")
+	decimalPolicyPath     = data.MustCompilePath("decimal_policy")
+	stringAsDecimalPath   = data.MustCompilePath("string_as_decimal")
+	py2StrPolicyPath      = data.MustCompilePath("py2_str_policy")
+	handleUnsupportedPath = data.MustCompilePath("handle_unsupported")
+	handleTTLPath         = data.MustCompilePath("handle_ttl")
+	reloadWatchPath       = data.MustCompilePath("reload_watch_interval")
+	errorPolicyPath       = data.MustCompilePath("error_policy")
+	stateNamePath         = data.MustCompilePath("state_name")
+
+	mapKeyPolicies = map[string]py.MapKeyPolicy{
+		"skip":  py.MapKeySkip,
+		"str":   py.MapKeyString,
+		"error": py.MapKeyError,
+	}
+
+	decimalPolicies = map[string]py.DecimalPolicy{
+		"float":  py.DecimalFloat,
+		"string": py.DecimalString,
+	}
+
+	py2StrPolicies = map[string]py.Py2StrPolicy{
+		"string":       py.Py2StrString,
+		"blob":         py.Py2StrBlob,
+		"utf8_or_blob": py.Py2StrUTF8OrBlob,
+	}
 )
 
 // ExtractBaseParams extracts parameters for Base from parameters given in
@@ -77,6 +187,12 @@ func ExtractBaseParams(params data.Map, removeBaseKeys bool) (*BaseParams, error
 		bp.ModuleName = moduleName
 	}
 
+	if ms, err := params.Get(moduleSourcePath); err == nil {
+		if bp.ModuleSource, err = data.AsString(ms); err != nil {
+			return nil, err
+		}
+	}
+
 	if cn, err := params.Get(classNamePath); err != nil {
 		return nil, err
 	} else if className, err := data.AsString(cn); err != nil {
@@ -92,14 +208,161 @@ func ExtractBaseParams(params data.Map, removeBaseKeys bool) (*BaseParams, error
 		}
 	}
 
+	if mkp, err := params.Get(mapKeyPolicyPath); err == nil {
+		bp.MapKeyPolicy, err = data.AsString(mkp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if dp, err := params.Get(decimalPolicyPath); err == nil {
+		bp.DecimalPolicy, err = data.AsString(dp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if sad, err := params.Get(stringAsDecimalPath); err == nil {
+		b, err := data.AsBool(sad)
+		if err != nil {
+			return nil, err
+		}
+		bp.StringAsDecimal = &b
+	}
+
+	if psp, err := params.Get(py2StrPolicyPath); err == nil {
+		bp.Py2StrPolicy, err = data.AsString(psp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if hu, err := params.Get(handleUnsupportedPath); err == nil {
+		b, err := data.AsBool(hu)
+		if err != nil {
+			return nil, err
+		}
+		bp.HandleUnsupported = &b
+	}
+
+	if ttl, err := params.Get(handleTTLPath); err == nil {
+		bp.HandleTTL, err = data.ToFloat(ttl)
+		if err != nil {
+			return nil, err
+		}
+		if bp.HandleTTL <= 0 {
+			return nil, fmt.Errorf("handle_ttl must be positive: %v", bp.HandleTTL)
+		}
+	}
+
+	if rw, err := params.Get(reloadWatchPath); err == nil {
+		bp.ReloadWatchInterval, err = data.ToFloat(rw)
+		if err != nil {
+			return nil, err
+		}
+		if bp.ReloadWatchInterval <= 0 {
+			return nil, fmt.Errorf("reload_watch_interval must be positive: %v",
+				bp.ReloadWatchInterval)
+		}
+		if bp.ModuleSource != "" {
+			return nil, errors.New(
+				"reload_watch_interval cannot be used with module_source")
+		}
+	}
+
+	if ep, err := params.Get(errorPolicyPath); err == nil {
+		m, err := data.AsMap(ep)
+		if err != nil {
+			return nil, fmt.Errorf("error_policy must be a map: %v", err)
+		}
+		bp.ErrorPolicy = make(map[string]string, len(m))
+		for class, action := range m {
+			if bp.ErrorPolicy[class], err = data.AsString(action); err != nil {
+				return nil, fmt.Errorf("invalid error_policy for '%v': %v", class, err)
+			}
+		}
+		if _, err := parseErrorPolicy(bp.ErrorPolicy); err != nil {
+			return nil, err
+		}
+	}
+
+	if sn, err := params.Get(stateNamePath); err == nil {
+		if bp.StateName, err = data.AsString(sn); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := bp.convertOptions(); err != nil {
+		return nil, err
+	}
+
 	if removeBaseKeys {
-		for _, k := range []string{"module_path", "module_name", "class_name", "write_method"} {
+		for _, k := range []string{"module_path", "module_name", "module_source", "class_name",
+			"write_method", "map_key_policy", "decimal_policy",
+			"string_as_decimal", "py2_str_policy", "handle_unsupported",
+			"handle_ttl", "reload_watch_interval", "error_policy", "state_name"} {
 			delete(params, k)
 		}
 	}
 	return bp, nil
 }
 
+// convertOptions returns options to convert values passed to or returned from
+// the Python UDS. Options which aren't specified in bp are the default ones.
+func (bp *BaseParams) convertOptions() (*py.ConvertOptions, error) {
+	opts := py.DefaultConvertOptions()
+	if bp.MapKeyPolicy != "" {
+		p, ok := mapKeyPolicies[bp.MapKeyPolicy]
+		if !ok {
+			return nil, fmt.Errorf(
+				"map_key_policy must be one of skip, str, or error: %v",
+				bp.MapKeyPolicy)
+		}
+		opts.MapKey = p
+	}
+	if bp.DecimalPolicy != "" {
+		p, ok := decimalPolicies[bp.DecimalPolicy]
+		if !ok {
+			return nil, fmt.Errorf(
+				"decimal_policy must be float or string: %v", bp.DecimalPolicy)
+		}
+		opts.Decimal = p
+	}
+	if bp.Py2StrPolicy != "" {
+		p, ok := py2StrPolicies[bp.Py2StrPolicy]
+		if !ok {
+			return nil, fmt.Errorf(
+				"py2_str_policy must be one of string, blob, or utf8_or_blob: %v",
+				bp.Py2StrPolicy)
+		}
+		opts.Py2Str = p
+	}
+	if bp.StringAsDecimal != nil {
+		opts.StringAsDecimal = *bp.StringAsDecimal
+	}
+	if bp.HandleUnsupported != nil {
+		opts.HandleUnsupported = *bp.HandleUnsupported
+	}
+	if bp.HandleTTL > 0 {
+		opts.HandleTTL = time.Duration(bp.HandleTTL * float64(time.Second))
+	}
+	return &opts, nil
+}
+
+// createParams returns parameters passed to 'create' static method when the
+// state was created. It returns an error when they aren't saved.
+func (bp *BaseParams) createParams() (data.Map, error) {
+	if bp.CreateParams == nil {
+		return nil, errors.New(
+			"the state cannot be re-created because it was saved without parameters of 'create'")
+	}
+	m, err := data.UnmarshalMsgpack(bp.CreateParams)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode parameters of 'create': %v", err)
+	}
+	return m, nil
+}
+
 // ExtractBaseLoadParams extracts parameters for Base from parameters given in
 // a SET clause of LOAD STATE statement. If removeBaseKeys is true, this
 // function removes base parameters from params and only other parameters
@@ -116,33 +379,101 @@ func ExtractBaseLoadParams(params data.Map, removeBaseKeys bool) (*BaseLoadParam
 type Base struct {
 	params BaseParams
 	ins    *py.ObjectInstance
+
+	// opts has *derivedConvertOptions built from the default options of py
+	// package and params. It's built again when the default options are
+	// changed so that the state follows py.SetDefaultConvertOptions.
+	opts atomic.Value
+
+	// instanceParams has parameters passed to 'create' or 'load' static
+	// method when the current instance was created. They're passed to 'load'
+	// static method by Reload. Parameters of 'create' are always taken from
+	// params.CreateParams because instanceParams of a loaded state have
+	// parameters of 'load'.
+	instanceParams data.Map
+
+	// generation is incremented every time the instance is replaced. It's
+	// read with read-lock and written with write-lock.
+	generation uint64
+
+	// errorPolicy decides actions taken for exceptions. It's nil when
+	// error_policy isn't specified.
+	errorPolicy *errorPolicy
+
+	// callCtx is passed to every call of the Python class and the instance
+	// so that they can use the sensorbee Python module. It's nil when the
+	// state isn't created with a context.
+	callCtx *py.CallContext
+
+	// named is 1 when the log fields of callCtx have the name of the state.
+	// It's accessed atomically.
+	named uint32
+
+	// nameSearched is 1 when the name of the state has been searched for in
+	// the registry. It's accessed atomically.
+	nameSearched uint32
+
+	// obj has py.Object of the current instance. It's read by PyObjectNoGIL
+	// without the lock of the state.
+	obj atomic.Value
 }
 
-// NewBase creates a new Base state.
+// derivedConvertOptions has options derived from the default options of py
+// package. version is py.DefaultConvertOptionsVersion when opts was built.
+type derivedConvertOptions struct {
+	version uint64
+	opts    *py.ConvertOptions
+}
+
+// NewBase creates a new Base state. Python code of the state cannot use
+// functions of the sensorbee Python module which require SensorBee because
+// the state doesn't have a context of a topology.
 func NewBase(baseParams *BaseParams, params data.Map) (*Base, error) {
-	var (
-		ins py.ObjectInstance
-		err error
-	)
+	return newBase(nil, baseParams, params)
+}
 
-	ins, err = newPyInstance("create", baseParams, nil, params)
+// newBase creates a new Base state. When ctx isn't nil, the sensorbee Python
+// module is available with ctx in all methods of the instance including
+// 'create' static method.
+func newBase(ctx *core.Context, baseParams *BaseParams, params data.Map) (*Base, error) {
+	if _, err := baseParams.convertOptions(); err != nil {
+		return nil, err
+	}
+	policy, err := parseErrorPolicy(baseParams.ErrorPolicy)
 	if err != nil {
 		return nil, err
 	}
 
+	createParams, err := data.MarshalMsgpack(params)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode parameters of the state: %v", err)
+	}
+
 	s := Base{}
-	s.set(ins, baseParams)
+	if ctx != nil {
+		s.callCtx = newCallContext(ctx, baseParams)
+		s.named = newStateNamed(baseParams)
+	}
+	ins, err := newPyInstance("create", baseParams, nil, params, s.callCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	bp := *baseParams
+	bp.CreateParams = createParams
+	s.set(ins, &bp)
+	s.errorPolicy = policy
+	s.instanceParams = params.Copy()
 	return &s, nil
 }
 
-// newPyInstance creates a new Python class instance.
+// newPyInstance creates a new Python class instance. cc is provided to the
+// sensorbee Python module while the static method is running and can be nil.
 // User must call Release method to release a resource.
 func newPyInstance(createMethodName string, baseParams *BaseParams,
-	args []data.Value, kwdArgs data.Map) (py.ObjectInstance, error) {
+	args []data.Value, kwdArgs data.Map, cc *py.CallContext) (py.ObjectInstance, error) {
 	var null py.ObjectInstance
-	mainthread.AppendSysPath(baseParams.ModulePath)
-
-	mdl, err := py.LoadModule(baseParams.ModuleName)
+	mdl, err := loadPyModule(baseParams)
 	if err != nil {
 		return null, err
 	}
@@ -154,10 +485,53 @@ func newPyInstance(createMethodName string, baseParams *BaseParams,
 	}
 	defer class.Release()
 
-	ins, err := class.CallDirect(createMethodName, args, kwdArgs)
+	ins, err := class.CallDirectWithContext(cc, createMethodName, args, kwdArgs)
 	return py.ObjectInstance{Object: ins}, err
 }
 
+// loadPyModule loads the Python module of the state. User must call Release
+// method to release a resource.
+func loadPyModule(baseParams *BaseParams) (py.ObjectModule, error) {
+	if err := appendModulePath(baseParams.ModulePath); err != nil {
+		return py.ObjectModule{}, err
+	}
+	if baseParams.ModuleSource != "" {
+		return py.LoadModuleFromSource(baseParams.ModuleName,
+			baseParams.ModuleSource)
+	}
+	return py.LoadModule(baseParams.ModuleName)
+}
+
+// appendModulePath adds path to sys.path. When path is a zip archive or a
+// wheel, or a directory in it, the archive is checked in advance so that an
+// invalid archive is reported instead of a failure of importing the module.
+func appendModulePath(path string) error {
+	if archive, ok := moduleArchivePath(path); ok {
+		r, err := zip.OpenReader(archive)
+		if err != nil {
+			return fmt.Errorf("module_path '%v' isn't a valid zip archive: %v",
+				path, err)
+		}
+		r.Close()
+	}
+	return mainthread.AppendSysPath(path)
+}
+
+// moduleArchivePath returns the path of a zip archive or a wheel when path
+// refers to a file in it.
+func moduleArchivePath(path string) (string, bool) {
+	p := filepath.ToSlash(path)
+	for _, ext := range []string{".zip", ".whl"} {
+		if strings.HasSuffix(p, ext) {
+			return path, true
+		}
+		if i := strings.Index(p, ext+"/"); i >= 0 {
+			return filepath.FromSlash(p[:i+len(ext)]), true
+		}
+	}
+	return "", false
+}
+
 // LoadBase loads a new Base state.
 func LoadBase(ctx *core.Context, r io.Reader, params data.Map) (*Base, error) {
 	// TODO: copy params to another map
@@ -171,14 +545,111 @@ func LoadBase(ctx *core.Context, r io.Reader, params data.Map) (*Base, error) {
 }
 
 func (s *Base) set(ins py.ObjectInstance, baseParams *BaseParams) {
+	// The new object must be visible to PyObjectNoGIL before the old one is
+	// released.
+	s.obj.Store(ins.Object)
 	if s.ins != nil {
 		s.ins.Release()
 	}
 	s.params = *baseParams
+	s.opts.Store(&derivedConvertOptions{})
 	s.ins = &ins
+	s.generation++
+}
+
+// convertOptions returns options to convert values passed to or returned from
+// the instance. Options which aren't specified by parameters of the state are
+// the current default options of py package. The returned value must not be
+// modified.
+//
+// This method requires read-lock.
+func (s *Base) convertOptions() *py.ConvertOptions {
+	// The version is read before the default options so that options built
+	// from older default options are built again next time.
+	v := py.DefaultConvertOptionsVersion()
+	if d := s.opts.Load().(*derivedConvertOptions); d.opts != nil && d.version == v {
+		return d.opts
+	}
+
+	// The error is ignored because parameters are validated when the state is
+	// created or loaded.
+	opts, _ := s.params.convertOptions()
+	opts.HandleOwner = s
+	s.opts.Store(&derivedConvertOptions{version: v, opts: opts})
+	return opts
+}
+
+// newCallContext creates the context passed to the sensorbee Python module
+// while the instance is called.
+func newCallContext(ctx *core.Context, baseParams *BaseParams) *py.CallContext {
+	cc := &py.CallContext{
+		Context: ctx,
+		LogFields: map[string]interface{}{
+			"module_name": baseParams.ModuleName,
+			"class_name":  baseParams.ClassName,
+		},
+	}
+	if baseParams.StateName != "" {
+		cc.LogFields["pystate"] = baseParams.StateName
+	}
+	return cc
+}
+
+// newStateNamed returns the initial value of Base.named for the context
+// created by newCallContext.
+func newStateNamed(baseParams *BaseParams) uint32 {
+	if baseParams.StateName != "" {
+		return 1
+	}
+	return 0
+}
+
+// callContext returns the context of the topology which the state belongs to.
+// It returns nil when the state isn't created by Creator.
+func (s *Base) callContext() *core.Context {
+	if s.callCtx == nil {
+		return nil
+	}
+	return s.callCtx.Context
+}
+
+// hasStateName returns true when logs written by the instance have the name
+// of the state.
+func (s *Base) hasStateName() bool {
+	return atomic.LoadUint32(&s.named) == 1
 }
 
-// Terminate terminates the state.
+// searchStateName returns true when the name of the state should be searched
+// for in the registry. It returns true only once so that the registry isn't
+// scanned every time when the state isn't registered.
+func (s *Base) searchStateName() bool {
+	return !s.hasStateName() && atomic.CompareAndSwapUint32(&s.nameSearched, 0, 1)
+}
+
+// setStateName adds the name of the state to logs written by the instance
+// when they don't have it yet. Base doesn't know its name until it's looked up
+// by the name unless state_name parameter is given because SensorBee doesn't
+// pass the name when it creates a state.
+func (s *Base) setStateName(name string) {
+	if s.callCtx == nil || !atomic.CompareAndSwapUint32(&s.named, 0, 1) {
+		return
+	}
+	// LogFields is only read on the main thread.
+	mainthread.ExecSync(func() {
+		s.callCtx.LogFields["pystate"] = name
+	})
+}
+
+// PyObjectNoGIL returns the Python object of the instance. This method is
+// called by the sensorbee Python module on the main thread and doesn't
+// require any lock. The returned object is valid while the main thread is
+// running Python code because the object is released on the main thread.
+func (s *Base) PyObjectNoGIL() py.Object {
+	o, _ := s.obj.Load().(py.Object)
+	return o
+}
+
+// Terminate terminates the state. Handles created by the state are released.
 //
 // This method requires write-lock.
 func (s *Base) Terminate(ctx *core.Context) error {
@@ -187,10 +658,12 @@ func (s *Base) Terminate(ctx *core.Context) error {
 	}
 	var err error
 	if s.ins.CheckFunc("terminate") {
-		_, err = s.ins.Call("terminate")
+		_, err = s.ins.CallKwWithContext(s.callCtx, s.convertOptions(), "terminate", nil, nil)
 	}
+	s.obj.Store(py.Object{})
 	s.ins.Release()
 	s.ins = nil
+	py.ReleaseHandles(s)
 	return err
 }
 
@@ -215,7 +688,55 @@ func (s *Base) Call(funcName string, dt ...data.Value) (data.Value, error) {
 	if s.ins == nil {
 		return nil, ErrAlreadyTerminated
 	}
-	return s.ins.Call(funcName, dt...)
+	return s.ins.CallKwWithContext(s.callCtx, s.convertOptions(), funcName, dt, nil)
+}
+
+// CallKw calls an instance method with positional arguments and keyword
+// arguments, and returns its value.
+//
+// This method requires read-lock for the same reason as Call.
+func (s *Base) CallKw(funcName string, args []data.Value, kwargs data.Map) (
+	data.Value, error) {
+	if s.ins == nil {
+		return nil, ErrAlreadyTerminated
+	}
+	return s.ins.CallKwWithContext(s.callCtx, s.convertOptions(), funcName, args, kwargs)
+}
+
+// CallKeep calls an instance method like CallKw. However, it returns a handle
+// of the return value instead of converting it. See py.ReleaseHandle for
+// details of handles.
+//
+// This method requires read-lock for the same reason as Call.
+func (s *Base) CallKeep(funcName string, args []data.Value, kwargs data.Map) (
+	data.Value, error) {
+	if s.ins == nil {
+		return nil, ErrAlreadyTerminated
+	}
+	opts := *s.convertOptions()
+	opts.KeepResult = true
+	return s.ins.CallKwWithContext(s.callCtx, &opts, funcName, args, kwargs)
+}
+
+// GetAttr returns the value of the attribute of the Python UDS.
+//
+// This method requires read-lock.
+func (s *Base) GetAttr(name string) (data.Value, error) {
+	if s.ins == nil {
+		return nil, ErrAlreadyTerminated
+	}
+	return s.ins.GetAttrWithOptions(s.convertOptions(), name)
+}
+
+// SetAttr sets the value to the attribute of the Python UDS.
+//
+// Unlike Call, this method is regarded as a modification of the state.
+// Therefore, this method requires write-lock.
+func (s *Base) SetAttr(name string, v data.Value) error {
+	if s.ins == nil {
+		return ErrAlreadyTerminated
+	}
+	return s.ins.SetAttrWithOptions(s.convertOptions(), name, v)
 }
 
 // Write calls "write" function of the Python UDS.
@@ -226,7 +747,8 @@ func (s *Base) Write(ctx *core.Context, t *core.Tuple) error {
 	if s.ins == nil {
 		return ErrAlreadyTerminated
 	}
-	_, err := s.ins.Call(s.params.WriteMethodName, t.Data)
+	_, err := s.ins.CallKwWithContext(s.callCtx, s.convertOptions(), s.params.WriteMethodName,
+		[]data.Value{t.Data}, nil)
 	return err
 }
 
@@ -262,7 +784,8 @@ func (s *Base) Save(ctx *core.Context, w io.Writer, params data.Map) error {
 		}
 	}()
 
-	_, err = s.ins.Call("save", data.String(filepath), params)
+	_, err = s.ins.CallKwWithContext(s.callCtx, s.convertOptions(), "save",
+		[]data.Value{data.String(filepath), params}, nil)
 	if err != nil {
 		return err
 	}
@@ -376,6 +899,13 @@ func (s *Base) loadPyMsgpackAndDataV1(ctx *core.Context, r io.Reader,
 	if err := dec.Decode(&saved); err != nil {
 		return err
 	}
+	if _, err := saved.convertOptions(); err != nil {
+		return err
+	}
+	policy, err := parseErrorPolicy(saved.ErrorPolicy)
+	if err != nil {
+		return err
+	}
 
 	temp, err := ioutil.TempFile("", "sensorbee_py_state") // TODO: TempDir should be configurable
 	if err != nil {
@@ -407,8 +937,18 @@ func (s *Base) loadPyMsgpackAndDataV1(ctx *core.Context, r io.Reader,
 	}
 	closeTemp()
 
+	if sn, err := params.Get(stateNamePath); err == nil {
+		if saved.StateName, err = data.AsString(sn); err != nil {
+			return err
+		}
+		delete(params, "state_name")
+	}
+	if s.callCtx == nil {
+		s.callCtx = newCallContext(ctx, &saved)
+		s.named = newStateNamed(&saved)
+	}
 	ins, err := newPyInstance("load", &saved, []data.Value{data.String(filepath)},
-		params)
+		params, s.callCtx)
 	if err != nil {
 		return err
 	}
@@ -420,10 +960,177 @@ func (s *Base) loadPyMsgpackAndDataV1(ctx *core.Context, r io.Reader,
 
 	// Exchange instance in `s` when Load succeeded
 	s.set(ins, &saved)
+	s.errorPolicy = policy
+	s.instanceParams = params.Copy()
+	return nil
+}
+
+// Reload reloads the Python module of the state and replaces the instance with
+// a new one of the reloaded class. The current instance is migrated to the new
+// one in the following order:
+//
+//  1. When the reloaded class has '__reload__' static method, it's called
+//     with the current instance and returns a new instance.
+//  2. When the current instance has 'save' method and the reloaded class has
+//     'load' static method, the current instance is saved to a temporary file
+//     and a new instance is loaded from it.
+//  3. Otherwise, a new instance is created by 'create' static method with the
+//     parameters passed when the current instance was created or loaded. The
+//     state of the current instance is lost.
+//
+// The current instance is released without calling 'terminate' because the
+// new instance may take over its resources. When reloading fails, the current
+// instance remains.
+//
+// This method requires write-lock.
+func (s *Base) Reload(ctx *core.Context) error {
+	if s.ins == nil {
+		return ErrAlreadyTerminated
+	}
+
+	mdl, err := loadPyModule(&s.params)
+	if err != nil {
+		return err
+	}
+	defer mdl.Release()
+	if s.params.ModuleSource == "" {
+		// A module created from source is already executed again by
+		// loadPyModule.
+		if err := mdl.Reload(); err != nil {
+			return err
+		}
+	}
+
+	class, err := mdl.GetClass(s.params.ClassName)
+	if err != nil {
+		return err
+	}
+	defer class.Release()
+
+	ins, err := s.migrate(ctx, &class)
+	if err != nil {
+		return fmt.Errorf("cannot migrate the state to the reloaded module: %w", err)
+	}
+	s.set(ins, &s.params)
 	return nil
 }
 
-// CallMethod calls an instance method and returns its value.
+// Recreate replaces the instance with a new one created by 'create' static
+// method with the parameters passed when the state was created. A loaded state
+// is also re-created with the parameters of 'create' saved with it. The
+// current instance is terminated after the new one is created. When creating
+// a new instance fails, the current instance remains. An error returned from
+// 'terminate' method of the current instance is logged to ctx when it isn't
+// nil.
+//
+// This method requires write-lock.
+func (s *Base) Recreate(ctx *core.Context) error {
+	if s.ins == nil {
+		return ErrAlreadyTerminated
+	}
+
+	params, err := s.params.createParams()
+	if err != nil {
+		return err
+	}
+	ins, err := newPyInstance("create", &s.params, nil, params, s.callCtx)
+	if err != nil {
+		return err
+	}
+	if s.ins.CheckFunc("terminate") {
+		if _, err := s.ins.CallKwWithContext(s.callCtx, s.convertOptions(), "terminate", nil, nil); err != nil && ctx != nil {
+			ctx.ErrLog(err).WithField("module_name", s.params.ModuleName).Warn(
+				"Cannot terminate the instance being re-created")
+		}
+	}
+	s.set(ins, &s.params)
+	return nil
+}
+
+func (s *Base) migrate(ctx *core.Context, class *py.ObjectInstance) (
+	py.ObjectInstance, error) {
+	var (
+		ins py.Object
+		err error
+	)
+	switch {
+	case class.CheckFunc("__reload__"):
+		h, hErr := py.NewHandle(&s.ins.Object, s.convertOptions())
+		if hErr != nil {
+			return py.ObjectInstance{}, hErr
+		}
+		defer py.ReleaseHandle(h)
+		ins, err = class.CallDirectWithContext(s.callCtx, "__reload__", []data.Value{h}, nil)
+
+	case s.ins.CheckFunc("save") && class.CheckFunc("load"):
+		ins, err = s.migrateBySaveLoad(ctx, class)
+
+	default:
+		params, pErr := s.params.createParams()
+		if pErr != nil {
+			return py.ObjectInstance{}, pErr
+		}
+		if ctx != nil {
+			ctx.Log().WithField("module_name", s.params.ModuleName).Warn(
+				"The state is re-created because it can be neither migrated nor saved")
+		}
+		ins, err = class.CallDirectWithContext(s.callCtx, "create", nil, params)
+	}
+	return py.ObjectInstance{Object: ins}, err
+}
+
+func (s *Base) migrateBySaveLoad(ctx *core.Context, class *py.ObjectInstance) (
+	py.Object, error) {
+	temp, err := ioutil.TempFile("", "sensorbee_py_state") // TODO: TempDir should be configurable
+	if err != nil {
+		return py.Object{}, fmt.Errorf(
+			"cannot create a temporary file for reloading: %v", err)
+	}
+	filepath := temp.Name()
+	if err := temp.Close(); err != nil && ctx != nil {
+		ctx.ErrLog(err).WithField("filepath", filepath).Warn(
+			"Cannot close the temporary file")
+	}
+	defer func() {
+		if err := os.Remove(filepath); err != nil && !os.IsNotExist(err) && ctx != nil {
+			ctx.ErrLog(err).WithField("filepath", filepath).Warn(
+				"Cannot remove the temporary file")
+		}
+	}()
+
+	if _, err := s.ins.CallKwWithContext(s.callCtx, s.convertOptions(), "save",
+		[]data.Value{data.String(filepath), data.Map{}}, nil); err != nil {
+		return py.Object{}, err
+	}
+	return class.CallDirectWithContext(s.callCtx, "load", []data.Value{data.String(filepath)},
+		s.instanceParams)
+}
+
+// moduleFile returns the path of the source file of the Python module.
+func (s *Base) moduleFile() (string, error) {
+	mdl, err := loadPyModule(&s.params)
+	if err != nil {
+		return "", err
+	}
+	defer mdl.Release()
+
+	v, err := mdl.GetAttr("__file__")
+	if err != nil {
+		return "", err
+	}
+	f, err := data.AsString(v)
+	if err != nil {
+		return "", err
+	}
+	if ext := filepath.Ext(f); ext == ".pyc" || ext == ".pyo" {
+		f = strings.TrimSuffix(f, ext) + ".py"
+	}
+	return f, nil
+}
+
+// CallMethod calls an instance method and returns its value. When the last
+// argument is a map created by Kwargs, it's passed as keyword arguments.
+// Other maps are positional arguments.
 func CallMethod(ctx *core.Context, stateName, funcName string, dt ...data.Value) (
 	data.Value, error) {
 	s, err := lookupPyState(ctx, stateName)
@@ -431,12 +1138,120 @@ func CallMethod(ctx *core.Context, stateName, funcName string, dt ...data.Value)
 		return nil, err
 	}
 
-	return s.Call(funcName, dt...)
+	args, kwargs := splitKeywordArgs(dt)
+	return s.CallKw(funcName, args, kwargs)
+}
+
+// KwargsKey is the only key of data.Map marking keyword arguments of
+// CallMethod and CallMethodKeep. The value of the key is a map having the
+// keyword arguments.
+const KwargsKey = "__sensorbee_py_kwargs__"
+
+// Kwargs marks kwargs as keyword arguments. When the returned value is the
+// last argument of CallMethod or CallMethodKeep, kwargs is passed to the
+// method as keyword arguments. This function is registered as "py_kwargs" UDF
+// by the plugin.
+func Kwargs(ctx *core.Context, kwargs data.Map) (data.Value, error) {
+	return data.Map{KwargsKey: kwargs}, nil
+}
+
+// splitKeywordArgs splits arguments of CallMethod into positional arguments
+// and keyword arguments. Only the last argument created by Kwargs is regarded
+// as keyword arguments.
+func splitKeywordArgs(dt []data.Value) ([]data.Value, data.Map) {
+	if len(dt) == 0 {
+		return dt, nil
+	}
+	m, err := data.AsMap(dt[len(dt)-1])
+	if err != nil || len(m) != 1 {
+		return dt, nil
+	}
+	v, ok := m[KwargsKey]
+	if !ok {
+		return dt, nil
+	}
+	kwargs, err := data.AsMap(v)
+	if err != nil {
+		return dt, nil
+	}
+	return dt[:len(dt)-1], kwargs
+}
+
+// CallMethodKeep calls an instance method like CallMethod. However, it returns
+// a handle of the return value instead of converting it, so that the Python
+// object can be passed to other calls as it is. The handle should be released
+// by ReleaseHandle after it's used. This function is registered as
+// "pystate_func_keep" UDF by the plugin.
+func CallMethodKeep(ctx *core.Context, stateName, funcName string, dt ...data.Value) (
+	data.Value, error) {
+	s, err := lookupPyState(ctx, stateName)
+	if err != nil {
+		return nil, err
+	}
+
+	args, kwargs := splitKeywordArgs(dt)
+	return s.CallKeep(funcName, args, kwargs)
+}
+
+// ReleaseHandle releases a handle returned from CallMethodKeep or a state
+// having "handle_unsupported" parameter. It always returns true when it
+// succeeds. This function is registered as "pystate_release_handle" UDF by the
+// plugin.
+func ReleaseHandle(ctx *core.Context, handle data.Value) (bool, error) {
+	if err := py.ReleaseHandle(handle); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetAttr returns the value of the attribute of the Python UDS. This function
+// is registered as "pystate_get" UDF by the plugin.
+func GetAttr(ctx *core.Context, stateName, attrName string) (data.Value, error) {
+	s, err := lookupPyState(ctx, stateName)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetAttr(attrName)
+}
+
+// SetAttr sets the value to the attribute of the Python UDS. It always returns
+// true when it succeeds. This function is registered as "pystate_set" UDF by
+// the plugin.
+func SetAttr(ctx *core.Context, stateName, attrName string, v data.Value) (bool, error) {
+	s, err := lookupPyState(ctx, stateName)
+	if err != nil {
+		return false, err
+	}
+	if err := s.SetAttr(attrName, v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Reload reloads the Python module of the state and migrates the instance to
+// the reloaded class. See Base.Reload for details. It always returns true when
+// it succeeds. This function is registered as "pystate_reload" UDF by the
+// plugin.
+func Reload(ctx *core.Context, stateName string) (bool, error) {
+	s, err := lookupPyState(ctx, stateName)
+	if err != nil {
+		return false, err
+	}
+	if err := s.Reload(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 type pyState interface {
 	core.SharedState
 	Call(funcName string, dt ...data.Value) (data.Value, error)
+	CallKw(funcName string, args []data.Value, kwargs data.Map) (data.Value, error)
+	CallKeep(funcName string, args []data.Value, kwargs data.Map) (data.Value, error)
+	GetAttr(name string) (data.Value, error)
+	SetAttr(name string, v data.Value) error
+	Reload(ctx *core.Context) error
+	setStateName(name string)
 }
 
 func lookupPyState(ctx *core.Context, stateName string) (pyState, error) {
@@ -446,6 +1261,7 @@ func lookupPyState(ctx *core.Context, stateName string) (pyState, error) {
 	}
 
 	if s, ok := st.(pyState); ok {
+		s.setStateName(stateName)
 		return s, nil
 	}
 
@@ -22,7 +22,21 @@ func (c *Creator) CreateState(ctx *core.Context, params data.Map) (
 	if err != nil {
 		return nil, err
 	}
-	return New(bp, params)
+	return newState(ctx, bp, params)
+}
+
+// newState creates a state with ctx and initializes it.
+func newState(ctx *core.Context, baseParams *BaseParams, params data.Map) (
+	core.SharedState, error) {
+	st, err := newWithContext(ctx, baseParams, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := initState(ctx, st); err != nil {
+		st.Terminate(ctx)
+		return nil, err
+	}
+	return st, nil
 }
 
 // LoadState loads saved state and creates a new instance from it.
@@ -36,12 +50,17 @@ func (c *Creator) LoadState(ctx *core.Context, r io.Reader, params data.Map) (
 		base: base,
 	}
 
+	var st core.SharedState = &s
 	if s.base.params.WriteMethodName != "" {
-		return &writableState{
+		st = &writableState{
 			// Although this copies a RWMutex, the mutex isn't being locked at
 			// the moment and it's safe to copy it now.
 			state: s,
-		}, nil
+		}
+	}
+	if err := initState(ctx, st); err != nil {
+		st.Terminate(ctx)
+		return nil, err
 	}
-	return &s, nil
+	return st, nil
 }
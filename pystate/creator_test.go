@@ -2,10 +2,17 @@ package pystate
 
 import (
 	"bytes"
+	"fmt"
 	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/py.v0"
 	"gopkg.in/sensorbee/sensorbee.v0/core"
 	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestCreateState(t *testing.T) {
@@ -41,6 +48,19 @@ func TestCreateState(t *testing.T) {
 						So(err, ShouldBeNil)
 						So(v, ShouldEqual, `called! arg is "test"`)
 					})
+					Convey("Then a trailing map created by Kwargs should be passed as keyword arguments", func() {
+						kwargs, err := Kwargs(ctx, data.Map{"scale": data.Int(3), "offset": data.Int(1)})
+						So(err, ShouldBeNil)
+						v, err := CallMethod(ctx, "creator_test", "predict", data.Int(2), kwargs)
+						So(err, ShouldBeNil)
+						So(v, ShouldEqual, data.Int(7))
+					})
+					Convey("Then a trailing map should be passed as a positional argument", func() {
+						v, err := CallMethod(ctx, "creator_test", "type_name",
+							data.Map{"scale": data.Int(3)})
+						So(err, ShouldBeNil)
+						So(v, ShouldEqual, "dict")
+					})
 					Convey("Then not exist instance method should not be called and return error", func() {
 						_, err = CallMethod(ctx, "creator_test", "not_exist_method")
 						So(err, ShouldNotBeNil)
@@ -122,6 +142,376 @@ func TestCreateState(t *testing.T) {
 				err = ps.Write(ctx, t)
 				So(err, ShouldBeNil)
 			})
+
+			Convey("Then the state written by a sink should have its name in logs", func() {
+				state, err := ct.CreateState(ctx, params)
+				So(err, ShouldBeNil)
+				Reset(func() {
+					state.Terminate(ctx)
+				})
+				So(ctx.SharedStates.Add("creator_test_sink", "pystate", state), ShouldBeNil)
+				Reset(func() {
+					ctx.SharedStates.Remove("creator_test_sink")
+				})
+				So(logFieldsOf(state)["pystate"], ShouldBeNil)
+
+				So(state.(*writableState).Write(ctx, &core.Tuple{}), ShouldBeNil)
+				So(logFieldsOf(state)["pystate"], ShouldEqual, "creator_test_sink")
+			})
+
+			Convey("Then the registry should only be searched once for the name", func() {
+				state, err := ct.CreateState(ctx, params)
+				So(err, ShouldBeNil)
+				Reset(func() {
+					state.Terminate(ctx)
+				})
+				ws := state.(*writableState)
+				So(ws.Write(ctx, &core.Tuple{}), ShouldBeNil)
+				So(ws.base.searchStateName(), ShouldBeFalse)
+				So(logFieldsOf(state)["pystate"], ShouldBeNil)
+			})
+		})
+
+		Convey("When the parameter has state_name", func() {
+			params := data.Map{
+				"module_name": data.String("_test_creator_module"),
+				"class_name":  data.String("TestClass4"),
+				"state_name":  data.String("creator_test12"),
+				"a":           data.Int(1),
+			}
+			state, err := ct.CreateState(ctx, params)
+			So(err, ShouldBeNil)
+			Reset(func() {
+				state.Terminate(ctx)
+			})
+
+			Convey("Then logs should have the name since the state is created", func() {
+				So(logFieldsOf(state)["pystate"], ShouldEqual, "creator_test12")
+			})
+
+			Convey("Then the name shouldn't be passed to create", func() {
+				So(ctx.SharedStates.Add("creator_test12", "pystate", state), ShouldBeNil)
+				defer ctx.SharedStates.Remove("creator_test12")
+				v, err := CallMethod(ctx, "creator_test12", "confirm")
+				So(err, ShouldBeNil)
+				So(v, ShouldResemble, data.Map{"a": data.Int(1)})
+			})
+
+			Convey("Then a state loaded with state_name should have the new name", func() {
+				buf := bytes.NewBuffer(nil)
+				So(state.(core.LoadableSharedState).Save(ctx, buf, data.Map{}), ShouldBeNil)
+				ls, err := ct.LoadState(ctx, buf, data.Map{
+					"state_name": data.String("creator_test13"),
+				})
+				So(err, ShouldBeNil)
+				defer ls.Terminate(ctx)
+				So(logFieldsOf(ls)["pystate"], ShouldEqual, "creator_test13")
+			})
+		})
+
+		Convey("When the parameter has map_key_policy", func() {
+			params := data.Map{
+				"module_name":    data.String("_test_creator_module"),
+				"class_name":     data.String("TestClass"),
+				"map_key_policy": data.String("str"),
+			}
+			Convey("Then the state should convert non-string keys with str", func() {
+				state, err := ct.CreateState(ctx, params)
+				So(err, ShouldBeNil)
+				Reset(func() {
+					state.Terminate(ctx)
+				})
+
+				ctx.SharedStates.Add("creator_test5", "creator_test5", state)
+				Reset(func() {
+					ctx.SharedStates.Remove("creator_test5")
+				})
+				v, err := CallMethod(ctx, "creator_test5", "histogram")
+				So(err, ShouldBeNil)
+				So(v, ShouldResemble, data.Map{
+					"1":   data.String("a"),
+					"key": data.String("b"),
+				})
+			})
+		})
+
+		Convey("When the parameter has invalid map_key_policy", func() {
+			params := data.Map{
+				"module_name":    data.String("_test_creator_module"),
+				"class_name":     data.String("TestClass"),
+				"map_key_policy": data.String("drop"),
+			}
+			Convey("Then a state should not be created", func() {
+				state, err := ct.CreateState(ctx, params)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "map_key_policy")
+				So(state, ShouldBeNil)
+			})
+		})
+
+		Convey("When the parameter has decimal options", func() {
+			params := data.Map{
+				"module_name":       data.String("_test_creator_module"),
+				"class_name":        data.String("TestClass"),
+				"decimal_policy":    data.String("string"),
+				"string_as_decimal": data.True,
+			}
+			Convey("Then the state should convert decimals exactly", func() {
+				state, err := ct.CreateState(ctx, params)
+				So(err, ShouldBeNil)
+				Reset(func() {
+					state.Terminate(ctx)
+				})
+
+				ctx.SharedStates.Add("creator_test6", "creator_test6", state)
+				Reset(func() {
+					ctx.SharedStates.Remove("creator_test6")
+				})
+				v, err := CallMethod(ctx, "creator_test6", "price")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, data.String("10.50"))
+
+				v, err = CallMethod(ctx, "creator_test6", "price", data.String("0.10"))
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, data.String("1.10"))
+			})
+		})
+
+		Convey("When a state has its own options and the default options are changed", func() {
+			params := data.Map{
+				"module_name":    data.String("_test_creator_module"),
+				"class_name":     data.String("TestClass"),
+				"decimal_policy": data.String("string"),
+			}
+			st, err := ct.CreateState(ctx, params)
+			So(err, ShouldBeNil)
+			Reset(func() {
+				st.Terminate(ctx)
+			})
+			ps := st.(*state)
+			v, err := ps.Call("infinity")
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, data.Float(math.Inf(1)))
+
+			py.SetDefaultConvertOptions(py.ConvertOptions{NonFinite: py.NonFiniteNull})
+			Reset(func() {
+				py.SetDefaultConvertOptions(py.ConvertOptions{})
+			})
+
+			Convey("Then the state should follow the new default options", func() {
+				v, err := ps.Call("infinity")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, data.Null{})
+			})
+
+			Convey("Then the state should keep its own options", func() {
+				v, err := ps.Call("price")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, data.String("10.50"))
+			})
+		})
+
+		Convey("When the parameter has handle_unsupported", func() {
+			params := data.Map{
+				"module_name":        data.String("_test_creator_module"),
+				"class_name":         data.String("TestClass"),
+				"handle_unsupported": data.True,
+				"handle_ttl":         data.Int(60),
+			}
+			Convey("Then the state should return handles of python objects", func() {
+				state, err := ct.CreateState(ctx, params)
+				So(err, ShouldBeNil)
+				Reset(func() {
+					state.Terminate(ctx)
+				})
+
+				ctx.SharedStates.Add("creator_test7", "creator_test7", state)
+				Reset(func() {
+					ctx.SharedStates.Remove("creator_test7")
+				})
+				h, err := CallMethod(ctx, "creator_test7", "make_object")
+				So(err, ShouldBeNil)
+				v, err := CallMethod(ctx, "creator_test7", "type_name", h)
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, "object")
+
+				ok, err := ReleaseHandle(ctx, h)
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+				_, err = CallMethod(ctx, "creator_test7", "type_name", h)
+				So(err, ShouldNotBeNil)
+
+				Convey("And handles of the state should be released when it's terminated", func() {
+					h, err := CallMethodKeep(ctx, "creator_test7", "price", data.Float(1))
+					So(err, ShouldBeNil)
+					So(state.Terminate(ctx), ShouldBeNil)
+					So(py.ReleaseHandle(h), ShouldNotBeNil)
+				})
+
+				Convey("And the state should keep a return value when asked", func() {
+					h, err := CallMethodKeep(ctx, "creator_test7", "price", data.Float(1))
+					So(err, ShouldBeNil)
+					Reset(func() {
+						ReleaseHandle(ctx, h)
+					})
+					v, err := CallMethod(ctx, "creator_test7", "type_name", h)
+					So(err, ShouldBeNil)
+					So(v, ShouldEqual, "float")
+				})
+			})
+		})
+
+		Convey("When a state is created with attributes", func() {
+			params := data.Map{
+				"module_name": data.String("_test_creator_module"),
+				"class_name":  data.String("TestClass2"),
+				"v1":          data.Int(1),
+				"v2":          data.String("a"),
+			}
+			state, err := ct.CreateState(ctx, params)
+			So(err, ShouldBeNil)
+			Reset(func() {
+				state.Terminate(ctx)
+			})
+			ctx.SharedStates.Add("creator_test8", "creator_test8", state)
+			Reset(func() {
+				ctx.SharedStates.Remove("creator_test8")
+			})
+
+			Convey("Then the attributes should be readable", func() {
+				v, err := GetAttr(ctx, "creator_test8", "v1")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 1)
+			})
+
+			Convey("Then the attributes should be writable", func() {
+				ok, err := SetAttr(ctx, "creator_test8", "v1", data.Int(2))
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+				v, err := CallMethod(ctx, "creator_test8", "confirm")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, "constructor init arg is v1=2, v2=a")
+			})
+
+			Convey("Then getting a missing attribute should fail", func() {
+				_, err := GetAttr(ctx, "creator_test8", "v3")
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("Then another pystate should read it through sensorbee module", func() {
+				st, err := ct.CreateState(ctx, data.Map{
+					"module_name": data.String("_test_creator_module"),
+					"class_name":  data.String("TestClass"),
+				})
+				So(err, ShouldBeNil)
+				defer st.Terminate(ctx)
+				So(ctx.SharedStates.Add("creator_test9", "creator_test9", st), ShouldBeNil)
+				defer ctx.SharedStates.Remove("creator_test9")
+
+				v, err := CallMethod(ctx, "creator_test9", "state_attr",
+					data.String("creator_test8"), data.String("v2"))
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, "a")
+
+				// The state learns its name when it's looked up.
+				So(logFieldsOf(st)["pystate"], ShouldEqual, "creator_test9")
+			})
+
+			Convey("Then another pystate should read it through sensorbee module in create and load", func() {
+				params := data.Map{
+					"module_name": data.String("_test_creator_module"),
+					"class_name":  data.String("TestClassCopyAttr"),
+					"source":      data.String("creator_test8"),
+					"attr":        data.String("v1"),
+				}
+				st, err := ct.CreateState(ctx, params)
+				So(err, ShouldBeNil)
+				defer st.Terminate(ctx)
+				So(ctx.SharedStates.Add("creator_test10", "creator_test10", st), ShouldBeNil)
+				defer ctx.SharedStates.Remove("creator_test10")
+
+				v, err := CallMethod(ctx, "creator_test10", "confirm")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 1)
+
+				buf := bytes.NewBuffer(nil)
+				So(st.(core.LoadableSharedState).Save(ctx, buf, data.Map{}), ShouldBeNil)
+				ls, err := ct.LoadState(ctx, buf, data.Map{
+					"source": data.String("creator_test8"),
+					"attr":   data.String("v2"),
+				})
+				So(err, ShouldBeNil)
+				defer ls.Terminate(ctx)
+				So(ctx.SharedStates.Add("creator_test11", "creator_test11", ls), ShouldBeNil)
+				defer ctx.SharedStates.Remove("creator_test11")
+
+				v, err = CallMethod(ctx, "creator_test11", "confirm")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, "a")
+			})
+		})
+
+		Convey("When the parameter has module_source", func() {
+			params := data.Map{
+				"module_name":   data.String("_test_inline_creator_module"),
+				"class_name":    data.String("Inline"),
+				"module_source": data.String("class Inline(object):\n    @staticmethod\n    def create(v):\n        self = Inline()\n        self.v = v\n        return self\n\n    def get(self):\n        return self.v\n"),
+				"v":             data.Int(3),
+			}
+			Convey("Then the state should be created from the source", func() {
+				st, err := ct.CreateState(ctx, params)
+				So(err, ShouldBeNil)
+				Reset(func() {
+					st.Terminate(ctx)
+				})
+				ps, ok := st.(*state)
+				So(ok, ShouldBeTrue)
+				v, err := ps.Call("get")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When the parameter has an invalid zip archive as module_path", func() {
+			params := data.Map{
+				"module_path": data.String("not_exist.zip"),
+				"module_name": data.String("_test_creator_module"),
+				"class_name":  data.String("TestClass"),
+			}
+			Convey("Then a state should not be created", func() {
+				state, err := ct.CreateState(ctx, params)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "zip archive")
+				So(state, ShouldBeNil)
+			})
+		})
+
+		Convey("When the parameter has invalid decimal_policy", func() {
+			params := data.Map{
+				"module_name":    data.String("_test_creator_module"),
+				"class_name":     data.String("TestClass"),
+				"decimal_policy": data.String("exact"),
+			}
+			Convey("Then a state should not be created", func() {
+				state, err := ct.CreateState(ctx, params)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "decimal_policy")
+				So(state, ShouldBeNil)
+			})
+		})
+
+		Convey("When the parameter has invalid py2_str_policy", func() {
+			params := data.Map{
+				"module_name":    data.String("_test_creator_module"),
+				"class_name":     data.String("TestClass"),
+				"py2_str_policy": data.String("bytes"),
+			}
+			Convey("Then a state should not be created", func() {
+				state, err := ct.CreateState(ctx, params)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "py2_str_policy")
+				So(state, ShouldBeNil)
+			})
 		})
 
 		Convey("When the parameter lacks module name", func() {
@@ -150,6 +540,20 @@ func TestCreateState(t *testing.T) {
 	})
 }
 
+// logFieldsOf returns fields added to logs written by the pystate.
+func logFieldsOf(s core.SharedState) map[string]interface{} {
+	if ws, ok := s.(*writableState); ok {
+		return ws.base.callCtx.LogFields
+	}
+	return s.(*state).base.callCtx.LogFields
+}
+
+// recreateState re-creates the pystate as error_policy "recreate" does.
+func recreateState(ctx *core.Context, s core.SharedState) error {
+	ps := s.(*state)
+	return ps.recreate(ctx, ps.base.generation)
+}
+
 func TestSaveLoadState(t *testing.T) {
 	params := data.Map{
 		"a": data.Int(1),
@@ -216,6 +620,24 @@ func TestSaveLoadState(t *testing.T) {
 				So(p, ShouldResemble, params)
 			})
 		})
+
+		Convey("When re-creating a state loaded with other parameters", func() {
+			s2, err := c.LoadState(ctx, buf, data.Map{"c": data.Int(3)})
+			So(err, ShouldBeNil)
+			Reset(func() {
+				s2.Terminate(ctx)
+			})
+			So(ctx.SharedStates.Add("creator_test4_3", "py", s2), ShouldBeNil)
+			_, err = CallMethod(ctx, "creator_test4_3", "modify_params")
+			So(err, ShouldBeNil)
+			So(recreateState(ctx, s2), ShouldBeNil)
+
+			Convey("Then it should be created with the parameters of 'create'", func() {
+				p, err := CallMethod(ctx, "creator_test4_3", "confirm")
+				So(err, ShouldBeNil)
+				So(p, ShouldResemble, params)
+			})
+		})
 	})
 }
 
@@ -237,3 +659,224 @@ func TestPyStateTerminate(t *testing.T) {
 		})
 	})
 }
+
+const reloadCounterSource = `
+class Counter(object):
+    @staticmethod
+    def create(start=0):
+        self = Counter()
+        self.n = start
+        return self
+
+    def save(self, filepath, params):
+        with open(filepath, 'w') as f:
+            f.write(str(self.n))
+%v
+    def count(self):
+        self.n += %v
+        return self.n
+`
+
+// loadCounterMethod is a migration of reloadCounterSource which loads a
+// counter saved by save method.
+const loadCounterMethod = `
+    @staticmethod
+    def load(filepath, **params):
+        self = Counter()
+        with open(filepath) as f:
+            self.n = int(f.read())
+        return self
+`
+
+func writeReloadCounter(path string, step int, migration string, mtime time.Time) {
+	So(ioutil.WriteFile(path, []byte(fmt.Sprintf(reloadCounterSource, migration, step)), 0644), ShouldBeNil)
+	// Python 2 compares mtime in seconds with the cached bytecode.
+	So(os.Chtimes(path, mtime, mtime), ShouldBeNil)
+}
+
+// nModules is the number of modules created by TestReloadState. Each test
+// uses a different module name because a loaded module remains in
+// sys.modules even when the test is run again.
+var nModules = 0
+
+func TestReloadState(t *testing.T) {
+	ctx := core.NewContext(nil)
+	Convey("Given a pystate created from a module file", t, func() {
+		dir, err := ioutil.TempDir("", "sensorbee_pystate_test")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		nModules++
+		moduleName := fmt.Sprint("_test_reload_counter", nModules)
+		path := filepath.Join(dir, moduleName+".py")
+		now := time.Now()
+		writeReloadCounter(path, 1, "", now)
+
+		ct := Creator{}
+		st, err := ct.CreateState(ctx, data.Map{
+			"module_path": data.String(dir),
+			"module_name": data.String(moduleName),
+			"class_name":  data.String("Counter"),
+			"start":       data.Int(5),
+		})
+		So(err, ShouldBeNil)
+		Reset(func() {
+			st.Terminate(ctx)
+		})
+		ctx.SharedStates.Add("reload_test", "reload_test", st)
+		Reset(func() {
+			ctx.SharedStates.Remove("reload_test")
+		})
+		v, err := CallMethod(ctx, "reload_test", "count")
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, 6)
+
+		Convey("When the state which can only be re-created is reloaded without a context", func() {
+			writeReloadCounter(path, 3, "", now.Add(10*time.Second))
+			ps := st.(*state)
+			ps.rwm.Lock()
+			err := ps.base.Reload(nil)
+			ps.rwm.Unlock()
+			So(err, ShouldBeNil)
+
+			Convey("Then it should be re-created with the parameters", func() {
+				v, err := CallMethod(ctx, "reload_test", "count")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 8)
+			})
+		})
+
+		Convey("When the class has __reload__ and the state is reloaded", func() {
+			writeReloadCounter(path, 2, `
+    @staticmethod
+    def __reload__(old):
+        self = Counter()
+        self.n = old.n * 10
+        return self
+`, now.Add(10*time.Second))
+			ok, err := Reload(ctx, "reload_test")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			Convey("Then the instance should be migrated by __reload__", func() {
+				v, err := CallMethod(ctx, "reload_test", "count")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 62)
+			})
+		})
+
+		Convey("When the class has save and load and the state is reloaded", func() {
+			writeReloadCounter(path, 2, `
+    @staticmethod
+    def load(filepath, start=0):
+        self = Counter()
+        with open(filepath) as f:
+            self.n = int(f.read()) + 100
+        return self
+`, now.Add(10*time.Second))
+			ok, err := Reload(ctx, "reload_test")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			Convey("Then the instance should be migrated by save and load", func() {
+				v, err := CallMethod(ctx, "reload_test", "count")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 108)
+			})
+		})
+
+		Convey("When the class cannot migrate and the state is reloaded", func() {
+			writeReloadCounter(path, 3, "", now.Add(10*time.Second))
+			ok, err := Reload(ctx, "reload_test")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			Convey("Then the instance should be re-created", func() {
+				v, err := CallMethod(ctx, "reload_test", "count")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 8)
+			})
+		})
+
+		Convey("When the module is broken and the state is reloaded", func() {
+			So(ioutil.WriteFile(path, []byte("class Counter(:\n"), 0644), ShouldBeNil)
+			future := now.Add(10 * time.Second)
+			So(os.Chtimes(path, future, future), ShouldBeNil)
+			_, err := Reload(ctx, "reload_test")
+			So(err, ShouldNotBeNil)
+
+			Convey("Then the current instance should remain", func() {
+				v, err := CallMethod(ctx, "reload_test", "count")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 7)
+			})
+		})
+	})
+
+	Convey("Given a pystate watching its module file", t, func() {
+		dir, err := ioutil.TempDir("", "sensorbee_pystate_test")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		nModules++
+		moduleName := fmt.Sprint("_test_watched_counter", nModules)
+		path := filepath.Join(dir, moduleName+".py")
+		now := time.Now()
+		writeReloadCounter(path, 1, loadCounterMethod, now)
+
+		ct := Creator{}
+		st, err := ct.CreateState(ctx, data.Map{
+			"module_path":           data.String(dir),
+			"module_name":           data.String(moduleName),
+			"class_name":            data.String("Counter"),
+			"reload_watch_interval": data.Float(0.01),
+		})
+		So(err, ShouldBeNil)
+		Reset(func() {
+			st.Terminate(ctx)
+		})
+		ctx.SharedStates.Add("watch_test", "watch_test", st)
+		Reset(func() {
+			ctx.SharedStates.Remove("watch_test")
+		})
+
+		Convey("When a state not watching the module file loads the saved state", func() {
+			buf := bytes.NewBuffer(nil)
+			So(st.(core.LoadableSharedState).Save(ctx, buf, data.Map{}), ShouldBeNil)
+			st2, err := ct.CreateState(ctx, data.Map{
+				"module_path": data.String(dir),
+				"module_name": data.String(moduleName),
+				"class_name":  data.String("Counter"),
+			})
+			So(err, ShouldBeNil)
+			Reset(func() {
+				st2.Terminate(ctx)
+			})
+			So(st2.(*state).stopWatching, ShouldBeNil)
+			So(st2.(core.LoadableSharedState).Load(ctx, buf, data.Map{}), ShouldBeNil)
+
+			Convey("Then it should start watching the module file", func() {
+				So(st2.(*state).stopWatching, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When the module file is modified", func() {
+			writeReloadCounter(path, 10, "", now.Add(10*time.Second))
+
+			Convey("Then the state should be reloaded", func() {
+				var v data.Value
+				for i := 0; i < 300; i++ {
+					v, err = CallMethod(ctx, "watch_test", "count")
+					So(err, ShouldBeNil)
+					if v != data.Int(i+1) {
+						break
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+				So(v, ShouldEqual, 10)
+			})
+		})
+	})
+}
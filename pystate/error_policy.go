@@ -0,0 +1,133 @@
+package pystate
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/sensorbee/py.v0"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultErrorPolicyKey is the key of an error policy applied to exceptions
+// which don't match any class in the policy.
+const defaultErrorPolicyKey = "default"
+
+type errorAction int
+
+const (
+	// errorPropagate returns the error to the caller.
+	errorPropagate errorAction = iota
+
+	// errorNull returns NULL instead of the error. Write returns no error.
+	errorNull
+
+	// errorRetry calls the method again. The error is returned when the
+	// method still fails after retries.
+	errorRetry
+
+	// errorRecreate re-creates the instance by 'create' static method and
+	// returns the error.
+	errorRecreate
+)
+
+// errorRule is an action taken for an exception.
+type errorRule struct {
+	action  errorAction
+	retries int
+}
+
+// parseErrorRule parses an action of an error policy. It's one of "null",
+// "propagate", "recreate", "retry", or "retry N" where N is the number of
+// retries. "retry" retries once.
+func parseErrorRule(s string) (errorRule, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return errorRule{}, errors.New("the action is empty")
+	}
+	switch fields[0] {
+	case "null":
+		if len(fields) == 1 {
+			return errorRule{action: errorNull}, nil
+		}
+	case "propagate":
+		if len(fields) == 1 {
+			return errorRule{action: errorPropagate}, nil
+		}
+	case "recreate":
+		if len(fields) == 1 {
+			return errorRule{action: errorRecreate}, nil
+		}
+	case "retry":
+		switch len(fields) {
+		case 1:
+			return errorRule{action: errorRetry, retries: 1}, nil
+		case 2:
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n <= 0 {
+				return errorRule{}, fmt.Errorf(
+					"the number of retries must be a positive integer: %v", fields[1])
+			}
+			return errorRule{action: errorRetry, retries: n}, nil
+		}
+	}
+	return errorRule{}, fmt.Errorf(
+		"the action must be one of null, propagate, recreate, or retry N: %v", s)
+}
+
+// errorPolicy decides the action taken for an exception raised by the Python
+// UDS.
+type errorPolicy struct {
+	// rules has actions for exception classes.
+	rules map[string]errorRule
+	def   errorRule
+}
+
+// parseErrorPolicy parses the error policy given as "error_policy" parameter.
+// It returns nil when m is empty.
+func parseErrorPolicy(m map[string]string) (*errorPolicy, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	p := &errorPolicy{
+		rules: map[string]errorRule{},
+	}
+	for class, action := range m {
+		r, err := parseErrorRule(action)
+		if err != nil {
+			return nil, fmt.Errorf("invalid error_policy for '%v': %v", class, err)
+		}
+		if class == defaultErrorPolicyKey {
+			p.def = r
+		} else {
+			p.rules[class] = r
+		}
+	}
+	return p, nil
+}
+
+// rule returns the action taken for err. The action for the most specific
+// class of the exception is chosen. Errors which aren't Python exceptions,
+// such as conversion errors, are always propagated.
+func (p *errorPolicy) rule(err error) errorRule {
+	var e *py.Error
+	if p == nil || !errors.As(err, &e) {
+		return errorRule{action: errorPropagate}
+	}
+
+	// Classes are sorted so that the result doesn't depend on the order of
+	// the map when aliases of the same class are given.
+	classes := make([]string, 0, len(p.rules))
+	for c := range p.rules {
+		classes = append(classes, c)
+	}
+	sort.Strings(classes)
+
+	r, depth := p.def, -1
+	for _, c := range classes {
+		if d := e.ClassDepth(c); d >= 0 && (depth < 0 || d < depth) {
+			r, depth = p.rules[c], d
+		}
+	}
+	return r
+}
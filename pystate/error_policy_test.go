@@ -0,0 +1,158 @@
+package pystate
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestErrorPolicy(t *testing.T) {
+	ctx := core.NewContext(&core.ContextConfig{})
+	Convey("Given a pystate creator", t, func() {
+		ct := Creator{}
+		params := data.Map{
+			"module_name":  data.String("_test_creator_module"),
+			"class_name":   data.String("TestClassErrorPolicy"),
+			"write_method": data.String("write"),
+		}
+
+		Convey("When the error policy is invalid", func() {
+			for _, p := range []data.Value{
+				data.String("null"),
+				data.Map{"ValueError": data.Int(1)},
+				data.Map{"ValueError": data.String("ignore")},
+				data.Map{"ValueError": data.String("retry 0")},
+				data.Map{"ValueError": data.String("retry a")},
+				data.Map{"ValueError": data.String("null 1")},
+			} {
+				params["error_policy"] = p
+				_, err := ct.CreateState(ctx, params)
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("When the state has an error policy", func() {
+			params["error_policy"] = data.Map{
+				"IOError":    data.String("retry 2"),
+				"ValueError": data.String("null"),
+				"_test_creator_module.ErrorPolicyTestError": data.String("propagate"),
+				"default": data.String("recreate"),
+			}
+			st, err := ct.CreateState(ctx, params)
+			So(err, ShouldBeNil)
+			Reset(func() {
+				st.Terminate(ctx)
+			})
+			So(ctx.SharedStates.Add("error_policy_test", "error_policy_test", st), ShouldBeNil)
+			Reset(func() {
+				ctx.SharedStates.Remove("error_policy_test")
+			})
+			call := func(kind string, times int) (data.Value, error) {
+				return CallMethod(ctx, "error_policy_test", "fail",
+					data.String(kind), data.Int(times))
+			}
+			attr := func(name string) data.Value {
+				v, err := GetAttr(ctx, "error_policy_test", name)
+				So(err, ShouldBeNil)
+				return v
+			}
+
+			Convey("Then a transient exception should be retried", func() {
+				v, err := call("io", 2)
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, data.Int(3))
+			})
+
+			Convey("Then an exception should be propagated after retries", func() {
+				_, err := call("io", 3)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "transient error")
+				So(attr("calls"), ShouldEqual, data.Int(3))
+			})
+
+			Convey("Then NULL should be returned for a matching exception", func() {
+				v, err := call("value", 1)
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, data.Null{})
+			})
+
+			Convey("Then the most specific class should be chosen", func() {
+				_, err := call("custom", 1)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "custom error")
+			})
+
+			Convey("Then the state should be re-created by the default policy", func() {
+				g := attr("generation")
+				_, err := call("runtime", 1)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "runtime error")
+				So(attr("generation"), ShouldNotEqual, g)
+				So(attr("calls"), ShouldEqual, data.Int(0))
+			})
+
+			Convey("Then the state should be re-created only once for the same failure", func() {
+				ws := st.(*writableState)
+				gen := ws.base.generation
+				So(ws.recreate(ctx, gen), ShouldBeNil)
+				g := attr("generation")
+				So(ws.recreate(ctx, gen), ShouldBeNil)
+				So(attr("generation"), ShouldEqual, g)
+			})
+
+			Convey("Then a loaded state should be re-created with the parameters of create", func() {
+				buf := bytes.NewBuffer(nil)
+				So(st.(core.LoadableSharedState).Save(ctx, buf, data.Map{}), ShouldBeNil)
+				ls, err := ct.LoadState(ctx, buf, data.Map{"x": data.Int(1)})
+				So(err, ShouldBeNil)
+				Reset(func() {
+					ls.Terminate(ctx)
+				})
+				So(ctx.SharedStates.Add("error_policy_test_loaded", "error_policy_test", ls), ShouldBeNil)
+				Reset(func() {
+					ctx.SharedStates.Remove("error_policy_test_loaded")
+				})
+				v, err := GetAttr(ctx, "error_policy_test_loaded", "params")
+				So(err, ShouldBeNil)
+				So(v, ShouldResemble, data.Map{"x": data.Int(1)})
+
+				_, err = CallMethod(ctx, "error_policy_test_loaded", "fail",
+					data.String("runtime"), data.Int(1))
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldNotContainSubstring, "cannot re-create")
+				v, err = GetAttr(ctx, "error_policy_test_loaded", "params")
+				So(err, ShouldBeNil)
+				So(v, ShouldResemble, data.Map{})
+				_, err = GetAttr(ctx, "error_policy_test_loaded", "loaded")
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("Then errors which aren't exceptions should be propagated", func() {
+				_, err := CallMethod(ctx, "error_policy_test", "not_exist_method")
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("Then the policy should be applied to Write", func() {
+				ws, ok := st.(*writableState)
+				So(ok, ShouldBeTrue)
+				So(ws.Write(ctx, &core.Tuple{Data: data.Map{"a": data.Int(1)}}), ShouldBeNil)
+			})
+		})
+
+		Convey("When the state doesn't have an error policy", func() {
+			st, err := ct.CreateState(ctx, params)
+			So(err, ShouldBeNil)
+			Reset(func() {
+				st.Terminate(ctx)
+			})
+
+			Convey("Then Write should return an exception", func() {
+				ws, ok := st.(*writableState)
+				So(ok, ShouldBeTrue)
+				So(ws.Write(ctx, &core.Tuple{Data: data.Map{"a": data.Int(1)}}), ShouldNotBeNil)
+			})
+		})
+	})
+}
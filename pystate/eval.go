@@ -0,0 +1,87 @@
+package pystate
+
+import (
+	"gopkg.in/sensorbee/py.v0"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"sync"
+)
+
+// maxEvalFuncs is the maximum number of compiled expressions cached by Eval.
+const maxEvalFuncs = 1024
+
+// evalFuncEntry is a cached EvalFunc. refs is the number of callers using f.
+// f is released when it's evicted from the cache and no caller uses it.
+type evalFuncEntry struct {
+	f       *py.EvalFunc
+	refs    int
+	evicted bool
+}
+
+var evalFuncs = struct {
+	m  map[string]*evalFuncEntry
+	mu sync.Mutex
+}{
+	m: map[string]*evalFuncEntry{},
+}
+
+// Eval evaluates the Python expression with args. Arguments are bound to free
+// variables of the expression in order of their first appearance (see
+// py.EvalFunc for details). The compiled expression is cached so that it
+// isn't compiled every time. The expression can use the sensorbee Python
+// module with ctx. This function is registered as "py_eval" UDF by the plugin.
+func Eval(ctx *core.Context, expr string, args ...data.Value) (data.Value, error) {
+	e, err := acquireEvalFunc(expr)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseEvalFunc(e)
+	return e.f.CallWithContext(&py.CallContext{Context: ctx}, nil, args...)
+}
+
+// acquireEvalFunc returns the cached EvalFunc of expr. It compiles expr when
+// it isn't cached. The caller must call releaseEvalFunc after it's used.
+func acquireEvalFunc(expr string) (*evalFuncEntry, error) {
+	evalFuncs.mu.Lock()
+	defer evalFuncs.mu.Unlock()
+	if e, ok := evalFuncs.m[expr]; ok {
+		e.refs++
+		return e, nil
+	}
+
+	f, err := py.CompileEval(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(evalFuncs.m) >= maxEvalFuncs {
+		// Expressions are usually constants in BQL statements, so the cache
+		// is simply cleared when it's full.
+		evictEvalFuncs()
+	}
+	e := &evalFuncEntry{f: f, refs: 1}
+	evalFuncs.m[expr] = e
+	return e, nil
+}
+
+// releaseEvalFunc tells that the caller doesn't use e anymore.
+func releaseEvalFunc(e *evalFuncEntry) {
+	evalFuncs.mu.Lock()
+	defer evalFuncs.mu.Unlock()
+	e.refs--
+	if e.evicted && e.refs == 0 {
+		e.f.Release()
+	}
+}
+
+// evictEvalFuncs removes all expressions from the cache. EvalFuncs which are
+// being used are released by releaseEvalFunc later. The caller must hold the
+// lock of the cache.
+func evictEvalFuncs() {
+	for _, e := range evalFuncs.m {
+		e.evicted = true
+		if e.refs == 0 {
+			e.f.Release()
+		}
+	}
+	evalFuncs.m = map[string]*evalFuncEntry{}
+}
@@ -0,0 +1,53 @@
+package pystate
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	Convey("Given a context", t, func() {
+		ctx := core.NewContext(nil)
+
+		Convey("When evaluating an expression", func() {
+			v, err := Eval(ctx, "a + b * 2", data.Int(1), data.Int(2))
+
+			Convey("Then it should return the result", func() {
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 5)
+			})
+
+			Convey("Then the compiled expression should be cached", func() {
+				e1, err := acquireEvalFunc("a + b * 2")
+				So(err, ShouldBeNil)
+				defer releaseEvalFunc(e1)
+				e2, err := acquireEvalFunc("a + b * 2")
+				So(err, ShouldBeNil)
+				defer releaseEvalFunc(e2)
+				So(e1, ShouldEqual, e2)
+			})
+		})
+
+		Convey("When the cache is cleared while an expression is being used", func() {
+			e, err := acquireEvalFunc("a * 3")
+			So(err, ShouldBeNil)
+			evalFuncs.mu.Lock()
+			evictEvalFuncs()
+			evalFuncs.mu.Unlock()
+
+			Convey("Then the expression should still be usable", func() {
+				v, err := e.f.Call(data.Int(2))
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 6)
+
+				Convey("And it should be released after it's used", func() {
+					releaseEvalFunc(e)
+					_, err := e.f.Call(data.Int(2))
+					So(err, ShouldNotBeNil)
+				})
+			})
+		})
+	})
+}
@@ -1,11 +1,24 @@
 package plugin
 
 import (
+	"gopkg.in/sensorbee/py.v0"
 	"gopkg.in/sensorbee/py.v0/pystate"
 	"gopkg.in/sensorbee/sensorbee.v0/bql/udf"
 )
 
 func init() {
+	// Output of Python code called by pystate is written to the log of the
+	// topology.
+	if err := py.SetOutputRedirection(true); err != nil {
+		panic(err)
+	}
 	udf.MustRegisterGlobalUDSCreator("pystate", &pystate.Creator{})
 	udf.MustRegisterGlobalUDF("pystate_func", udf.MustConvertGeneric(pystate.CallMethod))
+	udf.MustRegisterGlobalUDF("pystate_get", udf.MustConvertGeneric(pystate.GetAttr))
+	udf.MustRegisterGlobalUDF("pystate_set", udf.MustConvertGeneric(pystate.SetAttr))
+	udf.MustRegisterGlobalUDF("pystate_reload", udf.MustConvertGeneric(pystate.Reload))
+	udf.MustRegisterGlobalUDF("pystate_func_keep", udf.MustConvertGeneric(pystate.CallMethodKeep))
+	udf.MustRegisterGlobalUDF("pystate_release_handle", udf.MustConvertGeneric(pystate.ReleaseHandle))
+	udf.MustRegisterGlobalUDF("py_eval", udf.MustConvertGeneric(pystate.Eval))
+	udf.MustRegisterGlobalUDF("py_kwargs", udf.MustConvertGeneric(pystate.Kwargs))
 }
@@ -16,7 +16,7 @@ type defaultCreator struct {
 
 func (c *defaultCreator) CreateState(ctx *core.Context, params data.Map) (
 	core.SharedState, error) {
-	return New(c.baseParams, params)
+	return newState(ctx, c.baseParams, params)
 }
 
 // MustRegisterPyUDSCreator is like MustRegisterGlobalUDSCreator for Python
@@ -1,10 +1,14 @@
 package pystate
 
 import (
+	"fmt"
+	"gopkg.in/sensorbee/py.v0"
 	"gopkg.in/sensorbee/sensorbee.v0/core"
 	"gopkg.in/sensorbee/sensorbee.v0/data"
 	"io"
+	"os"
 	"sync"
+	"time"
 )
 
 // state is a wrapper of a UDS written in Python. State is save/loadable,
@@ -14,11 +18,22 @@ type state struct {
 	// users of State cannot directly call methods of BaseState.
 	base *Base
 	rwm  sync.RWMutex
+
+	// stopWatching stops watching the module file. It's nil when the module
+	// file isn't watched.
+	stopWatching chan struct{}
 }
 
 // New creates `core.SharedState` for python constructor.
 func New(baseParams *BaseParams, params data.Map) (core.SharedState, error) {
-	bs, err := NewBase(baseParams, params)
+	return newWithContext(nil, baseParams, params)
+}
+
+// newWithContext creates a state whose Python code can use the sensorbee
+// Python module with ctx.
+func newWithContext(ctx *core.Context, baseParams *BaseParams, params data.Map) (
+	core.SharedState, error) {
+	bs, err := newBase(ctx, baseParams, params)
 	if err != nil {
 		return nil, err
 	}
@@ -40,14 +55,89 @@ func New(baseParams *BaseParams, params data.Map) (core.SharedState, error) {
 func (s *state) Terminate(ctx *core.Context) error {
 	s.rwm.Lock()
 	defer s.rwm.Unlock()
+	s.stopWatchingModule()
 	return s.base.Terminate(ctx)
 }
 
 func (s *state) Call(funcName string, dt ...data.Value) (data.Value, error) {
-	// See BaseState.Call's godoc comment for the reason of using RLock here.
+	return s.callWithErrorPolicy(s.base.callContext(), func() (data.Value, error) {
+		return s.base.Call(funcName, dt...)
+	})
+}
+
+func (s *state) CallKw(funcName string, args []data.Value, kwargs data.Map) (
+	data.Value, error) {
+	return s.callWithErrorPolicy(s.base.callContext(), func() (data.Value, error) {
+		return s.base.CallKw(funcName, args, kwargs)
+	})
+}
+
+func (s *state) CallKeep(funcName string, args []data.Value, kwargs data.Map) (
+	data.Value, error) {
+	return s.callWithErrorPolicy(s.base.callContext(), func() (data.Value, error) {
+		return s.base.CallKeep(funcName, args, kwargs)
+	})
+}
+
+// callWithErrorPolicy calls f with read-lock and applies the error policy of
+// the state to an exception raised by f. ctx is used to log an error while
+// re-creating the instance and can be nil.
+func (s *state) callWithErrorPolicy(ctx *core.Context, f func() (data.Value, error)) (
+	data.Value, error) {
+	for n := 0; ; n++ {
+		// See BaseState.Call's godoc comment for the reason of using RLock
+		// here.
+		s.rwm.RLock()
+		v, err := f()
+		var r errorRule
+		if err != nil {
+			r = s.base.errorPolicy.rule(err)
+		}
+		gen := s.base.generation
+		s.rwm.RUnlock()
+		if err == nil {
+			return v, nil
+		}
+
+		switch r.action {
+		case errorNull:
+			return data.Null{}, nil
+		case errorRetry:
+			if n < r.retries {
+				continue
+			}
+		case errorRecreate:
+			if rErr := s.recreate(ctx, gen); rErr != nil {
+				return nil, fmt.Errorf("%v (cannot re-create the state: %v)", err, rErr)
+			}
+		}
+		return nil, err
+	}
+}
+
+// recreate re-creates the instance when its generation is still gen. When
+// the instance has already been replaced, for example, because concurrent
+// calls failed on the same instance, it doesn't do anything so that the
+// instance is re-created only once for the failure.
+func (s *state) recreate(ctx *core.Context, gen uint64) error {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+	if s.base.generation != gen {
+		return nil
+	}
+	return s.base.Recreate(ctx)
+}
+
+func (s *state) GetAttr(name string) (data.Value, error) {
 	s.rwm.RLock()
 	defer s.rwm.RUnlock()
-	return s.base.Call(funcName, dt...)
+	return s.base.GetAttr(name)
+}
+
+func (s *state) SetAttr(name string, v data.Value) error {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+	return s.base.SetAttr(name, v)
 }
 
 func (s *state) Save(ctx *core.Context, w io.Writer, params data.Map) error {
@@ -59,7 +149,124 @@ func (s *state) Save(ctx *core.Context, w io.Writer, params data.Map) error {
 func (s *state) Load(ctx *core.Context, r io.Reader, params data.Map) error {
 	s.rwm.Lock()
 	defer s.rwm.Unlock()
-	return s.base.Load(ctx, r, params)
+	if err := s.base.Load(ctx, r, params); err != nil {
+		return err
+	}
+	// The loaded state may have another module file or
+	// reload_watch_interval.
+	s.stopWatchingModule()
+	return s.watchModule(ctx)
+}
+
+func (s *state) setStateName(name string) {
+	s.base.setStateName(name)
+}
+
+func (s *state) PyObjectNoGIL() py.Object {
+	// This method doesn't acquire the lock. See Base.PyObjectNoGIL.
+	return s.base.PyObjectNoGIL()
+}
+
+func (s *state) Reload(ctx *core.Context) error {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+	return s.base.Reload(ctx)
+}
+
+// initState initializes st created or loaded in ctx. It starts watching the
+// module file when st has reload_watch_interval parameter.
+func initState(ctx *core.Context, st core.SharedState) error {
+	var s *state
+	switch st := st.(type) {
+	case *state:
+		s = st
+	case *writableState:
+		s = &st.state
+	default:
+		return nil
+	}
+	return s.watchModule(ctx)
+}
+
+// findStateName returns the name of st registered in ctx. It returns an empty
+// string when st isn't registered.
+func findStateName(ctx *core.Context, st core.SharedState) string {
+	states, err := ctx.SharedStates.List()
+	if err != nil {
+		return ""
+	}
+	for name, s := range states {
+		if s == st {
+			return name
+		}
+	}
+	return ""
+}
+
+// watchModule starts watching the module file. The state is reloaded when the
+// modification time of the file changes.
+func (s *state) watchModule(ctx *core.Context) error {
+	interval := s.base.params.ReloadWatchInterval
+	if interval <= 0 {
+		return nil
+	}
+
+	file, err := s.base.moduleFile()
+	if err != nil {
+		return fmt.Errorf("cannot watch the module file: %v", err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return fmt.Errorf("cannot watch the module file: %v", err)
+	}
+
+	stop := make(chan struct{})
+	s.stopWatching = stop
+	go s.watch(ctx, file, info.ModTime(),
+		time.Duration(interval*float64(time.Second)), stop)
+	return nil
+}
+
+// stopWatchingModule stops watching the module file started by watchModule.
+//
+// This method requires write-lock.
+func (s *state) stopWatchingModule() {
+	if s.stopWatching != nil {
+		close(s.stopWatching)
+		s.stopWatching = nil
+	}
+}
+
+func (s *state) watch(ctx *core.Context, file string, modTime time.Time,
+	interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			continue // the file may be being replaced
+		}
+		if info.ModTime().Equal(modTime) {
+			continue
+		}
+		modTime = info.ModTime()
+
+		if err := s.Reload(ctx); err != nil {
+			if err == ErrAlreadyTerminated {
+				return
+			}
+			ctx.ErrLog(err).WithField("module_file", file).Error(
+				"Cannot reload the pystate")
+			continue
+		}
+		ctx.Log().WithField("module_file", file).Info("The pystate is reloaded")
+	}
 }
 
 // writableState is essentially same as state except its Write method support.
@@ -68,8 +275,15 @@ type writableState struct {
 }
 
 func (s *writableState) Write(ctx *core.Context, t *core.Tuple) error {
-	// See BaseState.Call's godoc comment for the reason of using RLock here.
-	s.rwm.RLock()
-	defer s.rwm.RUnlock()
-	return s.base.Write(ctx, t)
+	if s.base.searchStateName() {
+		// A sink writing to the state doesn't look it up by pystate
+		// functions, so the name is found from the registry once.
+		if name := findStateName(ctx, s); name != "" {
+			s.setStateName(name)
+		}
+	}
+	_, err := s.callWithErrorPolicy(ctx, func() (data.Value, error) {
+		return nil, s.base.Write(ctx, t)
+	})
+	return err
 }
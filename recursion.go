@@ -0,0 +1,74 @@
+package py
+
+/*
+#include "Python.h"
+*/
+import "C"
+import (
+	"fmt"
+
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+// DefaultMaxDepth is the maximum depth of nested values converted between
+// Python objects and data.Value when ConvertOptions.MaxDepth is 0.
+const DefaultMaxDepth = 1000
+
+var (
+	// py2goVisiting has Python objects being converted into data.Value, and
+	// py2goDepth is the number of them. They're used to detect circular
+	// references and too deeply nested objects. go2pyDepth is the depth of
+	// data.Value being converted into a Python object. They must only be
+	// accessed on the main thread.
+	py2goVisiting = map[*C.PyObject]struct{}{}
+	py2goDepth    int
+	go2pyDepth    int
+)
+
+func (opts *ConvertOptions) maxDepth() int {
+	if opts.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return opts.MaxDepth
+}
+
+// enterPyObject marks o as being converted into data.Value. It returns an
+// error when o is already being converted, which means o has a circular
+// reference, or when objects are nested deeper than the maximum depth.
+// leavePyObject must be called after o is converted.
+func enterPyObject(o *C.PyObject, opts *ConvertOptions) error {
+	if _, ok := py2goVisiting[o]; ok {
+		return fmt.Errorf("cannot convert python object having a circular reference: %v",
+			getPyTypeName(o))
+	}
+	if max := opts.maxDepth(); py2goDepth >= max {
+		return fmt.Errorf("python object is nested deeper than the maximum depth %v", max)
+	}
+	py2goVisiting[o] = struct{}{}
+	py2goDepth++
+	return nil
+}
+
+// leavePyObject unmarks o marked by enterPyObject.
+func leavePyObject(o *C.PyObject) {
+	delete(py2goVisiting, o)
+	py2goDepth--
+}
+
+// enterValue increments the depth of data.Value being converted into a Python
+// object. It returns an error when values are nested deeper than the maximum
+// depth. data.Value having a circular reference also results in this error.
+// leaveValue must be called after v is converted.
+func enterValue(v data.Value, opts *ConvertOptions) error {
+	if max := opts.maxDepth(); go2pyDepth >= max {
+		return fmt.Errorf("%v is nested deeper than the maximum depth %v (it may have a circular reference)",
+			v.Type(), max)
+	}
+	go2pyDepth++
+	return nil
+}
+
+// leaveValue decrements the depth incremented by enterValue.
+func leaveValue() {
+	go2pyDepth--
+}
@@ -0,0 +1,176 @@
+package py
+
+/*
+#include "Python.h"
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+// Functions in this file are called by the sensorbee Python module on the
+// main thread. They return a new reference, or NULL after setting a Python
+// exception.
+
+func setPyErr(exc *C.PyObject, msg string) *C.PyObject {
+	cMsg := C.CString(msg)
+	defer C.free(unsafe.Pointer(cMsg))
+	C.PyErr_SetString(exc, cMsg)
+	return nil
+}
+
+func pyNone() *C.PyObject {
+	C.Py_IncRef(C.Py_None)
+	return C.Py_None
+}
+
+// requireCallContext returns the current CallContext and ConvertOptions. It
+// returns nil after setting RuntimeError when the context isn't available.
+func requireCallContext(name string) (*CallContext, *ConvertOptions) {
+	cc := currentCallContext
+	if cc == nil || cc.Context == nil {
+		setPyErr(C.PyExc_RuntimeError, fmt.Sprintf(
+			"sensorbee.%v is only available in python code called by SensorBee", name))
+		return nil, nil
+	}
+	return cc, currentCallOptions
+}
+
+// callbackString converts o into a string for callbacks.
+func callbackString(o *C.PyObject, opts *ConvertOptions) (string, error) {
+	v, err := fromPyTypeObject(o, opts)
+	if err != nil {
+		return "", err
+	}
+	return data.ToString(v)
+}
+
+//export sensorbeeLog
+func sensorbeeLog(level, msg, fields *C.PyObject) *C.PyObject {
+	cc, opts := requireCallContext("log")
+	if cc == nil {
+		return nil
+	}
+	l, err := callbackString(level, opts)
+	if err != nil {
+		return setPyErr(C.PyExc_TypeError, err.Error())
+	}
+	m, err := callbackString(msg, opts)
+	if err != nil {
+		return setPyErr(C.PyExc_TypeError, err.Error())
+	}
+
+	e := cc.Context.Log().WithFields(cc.LogFields)
+	if currentCallName != "" {
+		e = e.WithField("method", currentCallName)
+	}
+	if fields != nil && fields != C.Py_None {
+		v, err := fromPyTypeObject(fields, opts)
+		if err != nil {
+			return setPyErr(C.PyExc_TypeError, err.Error())
+		}
+		m, err := data.AsMap(v)
+		if err != nil {
+			return setPyErr(C.PyExc_TypeError, fmt.Sprintf(
+				"fields must be a dict: %v", v.Type()))
+		}
+		for k, v := range m {
+			e = e.WithField(k, v)
+		}
+	}
+	switch l {
+	case "debug":
+		e.Debug(m)
+	case "info":
+		e.Info(m)
+	case "warning", "warn":
+		e.Warn(m)
+	case "error":
+		e.Error(m)
+	default:
+		return setPyErr(C.PyExc_ValueError, fmt.Sprintf(
+			"log level must be one of debug, info, warning, or error: %v", l))
+	}
+	return pyNone()
+}
+
+//export sensorbeeHasContext
+func sensorbeeHasContext() *C.PyObject {
+	cc := currentCallContext
+	if cc == nil || cc.Context == nil {
+		return C.PyBool_FromLong(0)
+	}
+	return C.PyBool_FromLong(1)
+}
+
+//export sensorbeePendingOutput
+func sensorbeePendingOutput() *C.PyObject {
+	pyOutputPending = true
+	return pyNone()
+}
+
+//export sensorbeeTopology
+func sensorbeeTopology() *C.PyObject {
+	cc, _ := requireCallContext("topology")
+	if cc == nil {
+		return nil
+	}
+	return newPyString(cc.Context.TopologyName())
+}
+
+//export sensorbeeGetState
+func sensorbeeGetState(name *C.PyObject) *C.PyObject {
+	cc, opts := requireCallContext("get_state")
+	if cc == nil {
+		return nil
+	}
+	n, err := callbackString(name, opts)
+	if err != nil {
+		return setPyErr(C.PyExc_TypeError, err.Error())
+	}
+
+	st, err := cc.Context.SharedStates.Get(n)
+	if err != nil {
+		return setPyErr(C.PyExc_KeyError, err.Error())
+	}
+	s, ok := st.(ObjectState)
+	if !ok {
+		return setPyErr(C.PyExc_TypeError, fmt.Sprintf(
+			"state '%v' doesn't have a python object", n))
+	}
+	o := s.PyObjectNoGIL()
+	if o.p == nil {
+		return setPyErr(C.PyExc_RuntimeError, fmt.Sprintf(
+			"state '%v' is already terminated", n))
+	}
+	C.Py_IncRef(o.p)
+	return o.p
+}
+
+//export sensorbeeEmit
+func sensorbeeEmit(value *C.PyObject) *C.PyObject {
+	cc, opts := requireCallContext("emit")
+	if cc == nil {
+		return nil
+	}
+	if cc.Emit == nil {
+		return setPyErr(C.PyExc_RuntimeError,
+			"the caller doesn't support emitting tuples")
+	}
+	v, err := fromPyTypeObject(value, opts)
+	if err != nil {
+		return setPyErr(C.PyExc_TypeError, err.Error())
+	}
+	m, err := data.AsMap(v)
+	if err != nil {
+		return setPyErr(C.PyExc_TypeError, fmt.Sprintf(
+			"a tuple must be a dict: %v", v.Type()))
+	}
+	if err := cc.Emit(m); err != nil {
+		return setPyErr(C.PyExc_RuntimeError, err.Error())
+	}
+	return pyNone()
+}
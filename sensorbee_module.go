@@ -0,0 +1,225 @@
+package py
+
+/*
+#include "Python.h"
+
+// They're implemented in sensorbee_callback.go.
+extern PyObject* sensorbeeLog(PyObject* level, PyObject* msg, PyObject* fields);
+extern PyObject* sensorbeeHasContext();
+extern PyObject* sensorbeePendingOutput();
+extern PyObject* sensorbeeTopology();
+extern PyObject* sensorbeeGetState(PyObject* name);
+extern PyObject* sensorbeeEmit(PyObject* value);
+
+static PyObject* sensorbee_log(PyObject* self, PyObject* args) {
+  PyObject* level;
+  PyObject* msg;
+  PyObject* fields = NULL;
+  if (!PyArg_ParseTuple(args, "OO|O:_log", &level, &msg, &fields)) {
+    return NULL;
+  }
+  return sensorbeeLog(level, msg, fields);
+}
+
+static PyObject* sensorbee_has_context(PyObject* self, PyObject* unused) {
+  return sensorbeeHasContext();
+}
+
+static PyObject* sensorbee_pending_output(PyObject* self, PyObject* unused) {
+  return sensorbeePendingOutput();
+}
+
+static PyObject* sensorbee_topology(PyObject* self, PyObject* unused) {
+  return sensorbeeTopology();
+}
+
+static PyObject* sensorbee_get_state(PyObject* self, PyObject* args) {
+  PyObject* name;
+  if (!PyArg_ParseTuple(args, "O:get_state", &name)) {
+    return NULL;
+  }
+  return sensorbeeGetState(name);
+}
+
+static PyObject* sensorbee_emit(PyObject* self, PyObject* args) {
+  PyObject* value;
+  if (!PyArg_ParseTuple(args, "O:emit", &value)) {
+    return NULL;
+  }
+  return sensorbeeEmit(value);
+}
+
+static PyMethodDef sensorbeeMethods[] = {
+  {"_log", sensorbee_log, METH_VARARGS,
+   "_log(level, msg, fields=None) writes msg to the log of SensorBee."},
+  {"_has_context", sensorbee_has_context, METH_NOARGS,
+   "_has_context() returns True when SensorBee is calling python code."},
+  {"_pending_output", sensorbee_pending_output, METH_NOARGS,
+   "_pending_output() tells that the output has a partial line."},
+  {"topology", sensorbee_topology, METH_NOARGS,
+   "topology() returns the name of the topology."},
+  {"get_state", sensorbee_get_state, METH_VARARGS,
+   "get_state(name) returns the Python object of the shared state."},
+  {"emit", sensorbee_emit, METH_VARARGS,
+   "emit(dict) emits a tuple."},
+  {NULL, NULL, 0, NULL}
+};
+
+#if PY_MAJOR_VERSION >= 3
+static struct PyModuleDef sensorbeeModuleDef = {
+  PyModuleDef_HEAD_INIT, "sensorbee", NULL, -1, sensorbeeMethods,
+};
+#endif
+
+// initSensorBeeModule creates sensorbee module and registers it to
+// sys.modules. It returns a borrowed reference.
+static PyObject* initSensorBeeModule() {
+#if PY_MAJOR_VERSION >= 3
+  PyObject* m = PyModule_Create(&sensorbeeModuleDef);
+  if (m == NULL) {
+    return NULL;
+  }
+  if (PyDict_SetItemString(PyImport_GetModuleDict(), "sensorbee", m) != 0) {
+    Py_DECREF(m);
+    return NULL;
+  }
+  Py_DECREF(m);
+  return m;
+#else
+  return Py_InitModule("sensorbee", sensorbeeMethods);
+#endif
+}
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"gopkg.in/sensorbee/py.v0/mainthread"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+// sensorbeeModuleSource defines functions of sensorbee module written in
+// Python.
+const sensorbeeModuleSource = `
+def log(level, msg, *args):
+    """log(level, msg, *args) writes msg % args to the log of SensorBee. level
+    must be one of 'debug', 'info', 'warning', or 'error'."""
+    if args:
+        msg = msg % args
+    _log(level, msg)
+
+def debug(msg, *args):
+    log('debug', msg, *args)
+
+def info(msg, *args):
+    log('info', msg, *args)
+
+def warning(msg, *args):
+    log('warning', msg, *args)
+
+warn = warning
+
+def error(msg, *args):
+    log('error', msg, *args)
+`
+
+// CallContext has information of SensorBee which Python code can access
+// through the "sensorbee" Python module:
+//
+//	import sensorbee
+//
+//	sensorbee.info('processing %s', name) # also debug, warning, and error
+//	sensorbee.topology()                  # the name of the topology
+//	sensorbee.get_state('model')          # the Python object of a pystate
+//	sensorbee.emit({'a': 1})              # emits a tuple
+//
+// The module is registered when this package is initialized, so it's always
+// available in Python code called from Go. A CallContext is given to Python
+// code by methods having "WithContext" suffix such as
+// ObjectInstance.CallKwWithContext. Functions of the module which require
+// SensorBee raise RuntimeError when Python code is called without a
+// CallContext. Output of print, logging, and warnings can also be written to
+// the log. See SetOutputRedirection.
+type CallContext struct {
+	// Context is the context of the topology. Logs are written to its logger
+	// and shared states are looked up from it.
+	Context *core.Context
+
+	// LogFields are added to logs written by Python code.
+	LogFields map[string]interface{}
+
+	// Emit emits a tuple. When it's nil, sensorbee.emit raises RuntimeError
+	// because the caller doesn't support emitting tuples.
+	Emit func(data.Map) error
+}
+
+// ObjectState is a shared state having a Python object. sensorbee.get_state
+// returns the Python object of such a state.
+type ObjectState interface {
+	core.SharedState
+
+	// PyObjectNoGIL returns the Python object of the state. It's called on
+	// the main thread while Python code is running, so it must not wait for
+	// anything which may wait for the main thread such as a lock of the
+	// state. Object.p is nil when the state doesn't have an object.
+	PyObjectNoGIL() Object
+}
+
+var (
+	// currentCallContext is CallContext of Python code being called, and
+	// currentCallOptions is its ConvertOptions. currentCallName is the name
+	// of the function. They're used by the sensorbee module. They must only be
+	// accessed on the main thread.
+	currentCallContext *CallContext
+	currentCallOptions *ConvertOptions
+	currentCallName    string
+)
+
+func init() {
+	ch := make(chan error)
+	mainthread.Exec(func() {
+		m := C.initSensorBeeModule() // borrowed reference
+		if m == nil {
+			C.PyErr_Clear()
+			ch <- errors.New("cannot create sensorbee module")
+			return
+		}
+		globals := C.PyModule_GetDict(m) // borrowed reference
+		builtins := C.CString("__builtins__")
+		defer C.free(unsafe.Pointer(builtins))
+		if C.PyDict_SetItemString(globals, builtins, C.PyEval_GetBuiltins()) != 0 {
+			ch <- fmt.Errorf("cannot set builtins to sensorbee module: %v", getPyErr())
+			return
+		}
+		if err := runPySource(sensorbeeModuleSource, C.Py_file_input, globals, globals); err != nil {
+			ch <- fmt.Errorf("cannot define functions of sensorbee module: %v", err)
+			return
+		}
+		ch <- nil
+	})
+	if err := <-ch; err != nil {
+		panic(err)
+	}
+}
+
+// enterCallContext makes cc and opts available from the sensorbee module while
+// the function `name` is called until the returned function is called. When
+// cc is nil, the CallContext of the enclosing call is kept.
+func enterCallContext(cc *CallContext, opts *ConvertOptions, name string) func() {
+	prevCC, prevOpts, prevName := currentCallContext, currentCallOptions, currentCallName
+	if cc == nil {
+		cc = prevCC
+	}
+	currentCallContext, currentCallOptions, currentCallName = cc, opts, name
+	return func() {
+		if prevOpts == nil {
+			// Partial lines written by the outermost call are logged
+			// before its context is gone.
+			flushPyOutput()
+		}
+		currentCallContext, currentCallOptions, currentCallName = prevCC, prevOpts, prevName
+	}
+}
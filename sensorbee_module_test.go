@@ -0,0 +1,219 @@
+package py
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/sensorbee/py.v0/mainthread"
+	"gopkg.in/sensorbee/sensorbee.v0/core"
+	"gopkg.in/sensorbee/sensorbee.v0/data"
+)
+
+type testObjectState struct {
+	ins ObjectInstance
+}
+
+func (s *testObjectState) Terminate(ctx *core.Context) error {
+	return nil
+}
+
+func (s *testObjectState) PyObjectNoGIL() Object {
+	return s.ins.Object
+}
+
+type testState struct{}
+
+func (s *testState) Terminate(ctx *core.Context) error {
+	return nil
+}
+
+func TestOutputRedirectionDisabledByDefault(t *testing.T) {
+	Convey("Given a python module using sensorbee module", t, func() {
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_sensorbee_module")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When output redirection isn't enabled", func() {
+			Convey("Then the output should go to the original stream", func() {
+				v, err := mdl.Call("output_redirected")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, data.False)
+			})
+		})
+	})
+}
+
+func TestSensorBeeModule(t *testing.T) {
+	Convey("Given a python module using sensorbee module", t, func() {
+		So(SetOutputRedirection(true), ShouldBeNil)
+		Reset(func() {
+			So(SetOutputRedirection(false), ShouldBeNil)
+		})
+
+		mainthread.AppendSysPath("")
+
+		mdl, err := LoadModule("_test_sensorbee_module")
+		So(err, ShouldBeNil)
+		So(mdl, ShouldNotBeNil)
+		Reset(func() {
+			mdl.Release()
+		})
+
+		Convey("When calling it without CallContext", func() {
+			_, err := mdl.Call("log", data.String("info"), data.String("a"))
+
+			Convey("Then it should fail", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "RuntimeError")
+			})
+		})
+
+		Convey("When writing output without CallContext", func() {
+			v, err := mdl.Call("write_without_context", data.String("a\n"))
+
+			Convey("Then it should be written to the original stream", func() {
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, "a\n")
+			})
+		})
+
+		Convey("When disabling output redirection", func() {
+			So(SetOutputRedirection(false), ShouldBeNil)
+
+			Convey("Then the original output should be restored", func() {
+				v, err := mdl.Call("output_redirected")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, data.False)
+			})
+
+			Convey("And enabling it again", func() {
+				So(SetOutputRedirection(true), ShouldBeNil)
+
+				Convey("Then the output should be redirected", func() {
+					v, err := mdl.Call("output_redirected")
+					So(err, ShouldBeNil)
+					So(v, ShouldEqual, data.True)
+				})
+			})
+		})
+
+		Convey("When calling it with CallContext", func() {
+			ctx := core.NewContext(nil)
+			var emitted []data.Map
+			cc := &CallContext{
+				Context: ctx,
+				Emit: func(m data.Map) error {
+					emitted = append(emitted, m)
+					return nil
+				},
+			}
+			call := func(name string, args ...data.Value) (data.Value, error) {
+				return mdl.CallKwWithContext(cc, nil, name, args, nil)
+			}
+
+			Convey("Then it should write logs", func() {
+				for _, l := range []string{"debug", "info", "warning", "warn", "error"} {
+					v, err := call("log", data.String(l),
+						data.String("%s log"), data.String(l))
+					So(err, ShouldBeNil)
+					So(v, ShouldEqual, data.True)
+				}
+			})
+
+			Convey("Then an invalid log level should fail", func() {
+				_, err := call("log", data.String("fatal"),
+					data.String("a"))
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "ValueError")
+			})
+
+			Convey("Then it should return the topology name", func() {
+				v, err := call("topology")
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, ctx.TopologyName())
+			})
+
+			Convey("Then it should read a shared state having a python object", func() {
+				ins, err := mdl.NewInstance("State", nil, nil)
+				So(err, ShouldBeNil)
+				defer ins.Release()
+				So(ctx.SharedStates.Add("py_state", "test", &testObjectState{ins: ins}), ShouldBeNil)
+				So(ctx.SharedStates.Add("go_state", "test", &testState{}), ShouldBeNil)
+
+				v, err := call("get_state_value", data.String("py_state"))
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, "state_value")
+
+				_, err = call("get_state_value", data.String("go_state"))
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "TypeError")
+
+				_, err = call("get_state_value", data.String("no_state"))
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "KeyError")
+			})
+
+			Convey("Then it should emit tuples", func() {
+				_, err := call("emit_all", data.Int(2))
+				So(err, ShouldBeNil)
+				So(emitted, ShouldResemble, []data.Map{{"i": data.Int(0)}, {"i": data.Int(1)}})
+			})
+
+			Convey("Then it should emit tuples from a generator", func() {
+				it, err := mdl.CallIterWithContext(cc, nil, "emit_lazily", data.Int(2))
+				So(err, ShouldBeNil)
+				defer it.Close()
+				_, _, err = it.Next()
+				So(err, ShouldBeNil)
+				So(emitted, ShouldResemble, []data.Map{{"i": data.Int(0)}})
+			})
+
+			Convey("Then emitting without Emit should fail", func() {
+				cc.Emit = nil
+				_, err := call("emit_all", data.Int(1))
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "RuntimeError")
+				So(err.Error(), ShouldContainSubstring, "emitting")
+			})
+
+			Convey("Then it should be available from a generator", func() {
+				it, err := mdl.CallIterWithContext(cc, nil, "topology_lazily", data.Int(2))
+				So(err, ShouldBeNil)
+				defer it.Close()
+				v, ok, err := it.Next()
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+				So(v, ShouldEqual, ctx.TopologyName())
+			})
+
+			Convey("Then it should log output of python code", func() {
+				v, err := call("write_output", data.String("a\nb"))
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, data.True)
+
+				Convey("And partial lines should be logged after the call", func() {
+					v, err := mdl.Call("pending_output")
+					So(err, ShouldBeNil)
+					So(v, ShouldEqual, "")
+				})
+			})
+
+			Convey("Then it should log records of logging and warnings", func() {
+				v, err := call("write_logs", data.String("a"))
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, data.True)
+			})
+
+			Convey("Then it should be kept while converting the return value", func() {
+				opts := &ConvertOptions{ObjectFallback: true}
+				v, err := mdl.CallKwWithContext(cc, opts, "log_on_conversion", nil, nil)
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, "logged")
+			})
+		})
+	})
+}
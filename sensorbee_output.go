@@ -0,0 +1,223 @@
+package py
+
+/*
+#include "Python.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"gopkg.in/sensorbee/py.v0/mainthread"
+)
+
+// sensorbeeOutputSource defines objects of sensorbee module redirecting
+// sys.stdout, sys.stderr, the logging root handler, and warnings.showwarning
+// to the log of SensorBee. They write to the original destinations when
+// Python code isn't called by SensorBee.
+const sensorbeeOutputSource = `
+import sys as _sys
+import logging as _logging
+import warnings as _warnings
+
+class _LogWriter(object):
+    """_LogWriter is a file-like object writing each line to the log."""
+
+    def __init__(self, level, stream, original):
+        self._level = level
+        self._stream = stream
+        self._original = original
+        self._buf = ''
+
+    def write(self, s):
+        if not _has_context():
+            if self._original is not None:
+                self._original.write(s)
+            return
+        self._buf += s
+        while '\n' in self._buf:
+            line, self._buf = self._buf.split('\n', 1)
+            _log(self._level, line, {'stream': self._stream})
+        if self._buf:
+            _pending_output()
+
+    def writelines(self, lines):
+        for l in lines:
+            self.write(l)
+
+    def flush(self):
+        if self._original is not None:
+            self._original.flush()
+
+    def _flush_buffer(self):
+        if self._buf:
+            buf, self._buf = self._buf, ''
+            _log(self._level, buf, {'stream': self._stream})
+
+    def isatty(self):
+        return False
+
+    def __getattr__(self, name):
+        return getattr(self._original, name)
+
+def _log_level(levelno):
+    if levelno >= _logging.ERROR:
+        return 'error'
+    if levelno >= _logging.WARNING:
+        return 'warning'
+    if levelno >= _logging.INFO:
+        return 'info'
+    return 'debug'
+
+class _LogHandler(_logging.Handler):
+    """_LogHandler is a handler of logging writing records to the log."""
+
+    def emit(self, record):
+        try:
+            msg = self.format(record)
+            if _has_context():
+                _log(_log_level(record.levelno), msg, {'logger': record.name})
+            elif _sys.__stderr__ is not None:
+                _sys.__stderr__.write(msg + '\n')
+        except Exception:
+            self.handleError(record)
+
+_originals = {}
+
+def _showwarning(message, category, filename, lineno, file=None, line=None):
+    if file is not None or not _has_context():
+        return _originals['showwarning'](message, category, filename, lineno, file, line)
+    msg = _warnings.formatwarning(message, category, filename, lineno, line)
+    _log('warning', msg.rstrip(), {'category': category.__name__})
+
+def _redirect_output():
+    if _originals:
+        return
+    root = _logging.getLogger()
+    _originals['stdout'] = _sys.stdout
+    _originals['stderr'] = _sys.stderr
+    _originals['showwarning'] = _warnings.showwarning
+    _originals['handlers'] = root.handlers[:]
+    _sys.stdout = _LogWriter('info', 'stdout', _sys.stdout)
+    _sys.stderr = _LogWriter('warning', 'stderr', _sys.stderr)
+    _warnings.showwarning = _showwarning
+    root.handlers = [_LogHandler()]
+
+def _restore_output():
+    if not _originals:
+        return
+    _flush_output()
+    _sys.stdout = _originals['stdout']
+    _sys.stderr = _originals['stderr']
+    _warnings.showwarning = _originals['showwarning']
+    _logging.getLogger().handlers = _originals['handlers']
+    _originals.clear()
+
+def _flush_output():
+    for s in (_sys.stdout, _sys.stderr):
+        if isinstance(s, _LogWriter):
+            s._flush_buffer()
+`
+
+var (
+	// sensorbeeModuleDict is the borrowed reference of the dict of sensorbee
+	// module.
+	sensorbeeModuleDict *C.PyObject
+
+	// pyOutputPending is true when sys.stdout or sys.stderr has a partial line
+	// which hasn't been logged yet. It must only be accessed on the main
+	// thread.
+	pyOutputPending bool
+)
+
+func init() {
+	ch := make(chan error)
+	mainthread.Exec(func() {
+		name := C.CString("sensorbee")
+		defer C.free(unsafe.Pointer(name))
+		m := C.PyImport_AddModule(name) // borrowed reference
+		if m == nil {
+			C.PyErr_Clear()
+			ch <- errors.New("sensorbee module isn't initialized")
+			return
+		}
+		sensorbeeModuleDict = C.PyModule_GetDict(m)
+		if err := runPySource(sensorbeeOutputSource, C.Py_file_input,
+			sensorbeeModuleDict, sensorbeeModuleDict); err != nil {
+			ch <- fmt.Errorf("cannot define output redirection of sensorbee module: %v", err)
+			return
+		}
+		ch <- nil
+	})
+	if err := <-ch; err != nil {
+		panic(err)
+	}
+}
+
+// SetOutputRedirection enables or disables redirection of sys.stdout,
+// sys.stderr, the root handler of the logging module, and warnings.showwarning
+// to the log of SensorBee. It's disabled by default and the pystate plugin
+// enables it.
+//
+// While it's enabled, lines written by Python code called with a CallContext
+// are written to CallContext.Context.Log() with its LogFields, "method" field
+// having the name of the called function, and a field describing the source
+// such as "stream", "logger", or "category". stdout is logged at info level,
+// and stderr and warnings are logged at warning level. Output written by
+// Python code not called by SensorBee goes to the original destinations.
+//
+// Users who use this package as a library and want Python to keep its own
+// standard streams and logging configuration shouldn't enable it.
+func SetOutputRedirection(enabled bool) error {
+	ch := make(chan error)
+	mainthread.Exec(func() {
+		ch <- setOutputRedirection(enabled)
+	})
+	return <-ch
+}
+
+func setOutputRedirection(enabled bool) error {
+	f := "_restore_output"
+	if enabled {
+		f = "_redirect_output"
+	}
+	if err := callSensorBeeModuleFunc(f); err != nil {
+		return fmt.Errorf("fail to set output redirection: %v", err)
+	}
+	return nil
+}
+
+// flushPyOutput logs partial lines written to sys.stdout and sys.stderr. A
+// pending Python exception is kept as it is.
+func flushPyOutput() {
+	if !pyOutputPending {
+		return
+	}
+	pyOutputPending = false
+
+	var typ, val, tb *C.PyObject
+	C.PyErr_Fetch(&typ, &val, &tb)
+	defer C.PyErr_Restore(typ, val, tb)
+	if err := callSensorBeeModuleFunc("_flush_output"); err != nil {
+		// There's no place to report the error.
+		return
+	}
+}
+
+// callSensorBeeModuleFunc calls a function of sensorbee module without
+// arguments.
+func callSensorBeeModuleFunc(name string) error {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	f := C.PyDict_GetItemString(sensorbeeModuleDict, cName) // borrowed reference
+	if f == nil {
+		return fmt.Errorf("sensorbee module doesn't have %v", name)
+	}
+	ret := C.PyObject_CallObject(f, nil)
+	if ret == nil {
+		return getPyErr()
+	}
+	C.Py_DecRef(ret)
+	return nil
+}